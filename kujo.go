@@ -2,20 +2,250 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/jelmersnoeck/kujo/pkg/kujo"
 )
 
 func main() {
-	reader := bufio.NewReader(os.Stdin)
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		explain(os.Args[2:])
+		return
+	}
+
+	var opts kujo.Options
+	kubeconfigNamespace := optionFlags(flag.CommandLine, &opts)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [file|directory|pattern ...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s explain [flags] [file|directory|pattern ...]\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Reads from stdin when no paths are given.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	defaultNamespace(&opts, *kubeconfigNamespace)
+	checkImageLock(&opts)
+
+	reader, err := inputReader(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	output, err := kujo.Convert(reader, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(strings.TrimSuffix(string(output), "\n"))
+}
+
+// explain runs the explain command, which outputs how the unique name of each
+// job is derived. With old paths, it outputs what changed between the old and
+// new input instead.
+func explain(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+
+	var opts kujo.Options
+	var old listFlag
+	kubeconfigNamespace := optionFlags(fs, &opts)
+	asJSON := fs.Bool("json", false, "output JSON instead of text")
+	fs.Var(&old, "old", "file, directory or pattern with the old input to compare with, can be repeated")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s explain [flags] [file|directory|pattern ...]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Explains how the unique job names are derived. Reads from stdin when no paths are given.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	defaultNamespace(&opts, *kubeconfigNamespace)
+	checkImageLock(&opts)
+
+	reader, err := inputReader(fs.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	output, err := kujo.SuffixJobs(reader)
+	explanations, err := kujo.Explain(reader, opts)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	fmt.Println(string(output[:len(output)-1]))
+	var result interface{} = explanations
+	text := kujo.FormatExplanations(explanations)
+	if len(old) > 0 {
+		oldReader, err := kujo.ReaderFromPaths(old)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		oldExplanations, err := kujo.Explain(oldReader, opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		differences := kujo.Compare(oldExplanations, explanations)
+		result = differences
+		text = kujo.FormatDifferences(differences)
+	}
+
+	if *asJSON {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		text = string(data)
+	}
+
+	fmt.Println(strings.TrimSuffix(text, "\n"))
+}
+
+// optionFlags registers the flags for the conversion options. The returned
+// flag tells if the namespace defaults to the one of the kubeconfig context.
+func optionFlags(fs *flag.FlagSet, opts *kujo.Options) *bool {
+	fs.BoolVar(&opts.FlattenLists, "flatten-lists", false, "output the items of List objects as separate documents")
+	fs.StringVar(&opts.Namespace, "namespace", "", "namespace for objects without a namespace (default \"default\")")
+	kubeconfigNamespace := fs.Bool("kubeconfig-namespace", false, "use the namespace of the current kubeconfig context when --namespace isn't set")
+	fs.BoolVar(&opts.SetNamespace, "set-namespace", false, "set the namespace on objects without a namespace in the output")
+	fs.StringVar(&opts.NameTemplate, "name-template", kujo.DefaultNameTemplate, "Go template for the unique job names, with the .Name, .Namespace, .Hash, .ShortHash and .Labels fields")
+	fs.BoolVar(&opts.StrictNames, "strict-names", false, "fail when a job name is too long instead of truncating it")
+	fs.BoolVar(&opts.StrictRefs, "strict-refs", false, "fail when a job references a ConfigMap or Secret which is not part of the input, unless the reference is optional")
+	fs.BoolVar(&opts.Canonicalize, "canonicalize", false, "normalize quantities, remove default values and sort unordered lists of the job spec before hashing it")
+	fs.Var((*listFlag)(&opts.HashExclude), "hash-exclude", "JSON pointer or dotted path of a job field to exclude from the hash, like spec.ttlSecondsAfterFinished, can be repeated")
+	fs.StringVar(&opts.BaseDir, "base-dir", ".", "directory the paths of the kujo.sphc.io/hash-paths and kujo.sphc.io/hash-git-path annotations are resolved in")
+	fs.Var(&imageLockFlag{opts: opts}, "image-lock", "YAML or JSON file which maps images to their digest, the digests are folded into the job hashes")
+	fs.BoolVar(&opts.PinImages, "pin-images", false, "replace the images of renamed jobs which are part of the image lock with their digest")
+	fs.BoolVar(&opts.SuffixConfig, "suffix-config", false, "suffix all ConfigMaps and Secrets with the hash of their content and rewrite the references to them")
+	fs.Var(&nameReferenceFlag{opts: opts}, "name-reference", "field which refers to jobs by name as Kind:path or group/Kind:path, like example.com/Database:spec.waitFor.job, can be repeated")
+	fs.BoolVar(&opts.InjectEnv, "inject-env", false, "add the KUJO_HASH, KUJO_JOB_NAME and KUJO_BASE_NAME environment variables to the containers of renamed jobs")
+	fs.Var(&workloadKindsFlag{opts: opts}, "kinds", "YAML or JSON file with the kinds of resources which are renamed next to Jobs and Pods, and where their pod specs live")
+	fs.BoolVar(&opts.PreserveFormatting, "preserve", false, "only change the updated values and keep the formatting of the input")
+	return kubeconfigNamespace
+}
+
+// defaultNamespace sets the namespace of the options to the namespace of the
+// current kubeconfig context when it isn't set and kubeconfig is enabled.
+func defaultNamespace(opts *kujo.Options, kubeconfig bool) {
+	if opts.Namespace != "" || !kubeconfig {
+		return
+	}
+
+	ns, err := kujo.KubeconfigNamespace(kujo.KubeconfigPaths())
+	if err != nil {
+		log.Printf("Could not read the namespace from the kubeconfig: %s", err)
+	} else if ns != "" {
+		log.Printf("Using the namespace '%s' of the current kubeconfig context", ns)
+	}
+	opts.Namespace = ns
+}
+
+// checkImageLock makes sure images are only pinned with an image lock.
+func checkImageLock(opts *kujo.Options) {
+	if opts.PinImages && opts.ImageLock == nil {
+		log.Fatal("Images can only be pinned with an image lock file, set it with --image-lock")
+	}
+}
+
+// imageLockFlag reads the image lock file into the options.
+type imageLockFlag struct {
+	opts *kujo.Options
+	path string
+}
+
+func (f *imageLockFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.path
+}
+
+func (f *imageLockFlag) Set(value string) error {
+	lock, err := kujo.ReadImageLock(value)
+	if err != nil {
+		return err
+	}
+
+	f.path = value
+	f.opts.ImageLock = lock
+	return nil
+}
+
+// workloadKindsFlag reads the workload kinds file into the options.
+type workloadKindsFlag struct {
+	opts *kujo.Options
+	path string
+}
+
+func (f *workloadKindsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.path
+}
+
+func (f *workloadKindsFlag) Set(value string) error {
+	kinds, err := kujo.ReadWorkloadKinds(value)
+	if err != nil {
+		return err
+	}
+
+	f.path = value
+	f.opts.WorkloadKinds = kinds
+	return nil
+}
+
+// nameReferenceFlag adds name references to the options.
+type nameReferenceFlag struct {
+	opts *kujo.Options
+}
+
+func (f *nameReferenceFlag) String() string {
+	if f == nil || f.opts == nil {
+		return ""
+	}
+
+	var refs []string
+	for _, ref := range f.opts.NameReferences {
+		kind := ref.Kind
+		if ref.Group != "" {
+			kind = ref.Group + "/" + kind
+		}
+		refs = append(refs, kind+":"+ref.Path)
+	}
+	return strings.Join(refs, ",")
+}
+
+func (f *nameReferenceFlag) Set(value string) error {
+	ref, err := kujo.ParseNameReference(value)
+	if err != nil {
+		return err
+	}
+
+	f.opts.NameReferences = append(f.opts.NameReferences, ref)
+	return nil
+}
+
+// listFlag is a flag which can be repeated to collect multiple values.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// inputReader returns a reader for the given paths. When no paths or only `-`
+// is given, stdin is used.
+func inputReader(paths []string) (io.Reader, error) {
+	if len(paths) == 0 || (len(paths) == 1 && paths[0] == "-") {
+		return bufio.NewReader(os.Stdin), nil
+	}
+
+	return kujo.ReaderFromPaths(paths)
 }
@@ -0,0 +1,343 @@
+package kujo
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// jobDefaults are the values the API server sets on a job when they are
+// omitted.
+var jobDefaults = map[string]interface{}{
+	"backoffLimit": int64(6),
+	"parallelism":  int64(1),
+}
+
+// podDefaults are the values the API server sets on a pod when they are
+// omitted. The default restart policy is left out, a job doesn't accept it.
+var podDefaults = map[string]interface{}{
+	"dnsPolicy":                     "ClusterFirst",
+	"enableServiceLinks":            true,
+	"schedulerName":                 "default-scheduler",
+	"terminationGracePeriodSeconds": int64(30),
+}
+
+// barePodDefaults are the values the API server sets on a bare pod, next to
+// the defaults of any pod.
+var barePodDefaults = map[string]interface{}{
+	"restartPolicy": "Always",
+}
+
+// containerDefaults are the values the API server sets on a container when
+// they are omitted. The default image pull policy depends on the image and is
+// handled separately.
+var containerDefaults = map[string]interface{}{
+	"terminationMessagePath":   "/dev/termination-log",
+	"terminationMessagePolicy": "File",
+}
+
+// defaultMode is the default file mode of volumes which project files.
+const defaultMode = int64(0644)
+
+// emptyFields are the fields which mean the same when they are empty and when
+// they are omitted. Other empty fields, like an `emptyDir: {}` volume, can't
+// be removed.
+var emptyFields = map[string]bool{
+	"annotations":      true,
+	"args":             true,
+	"command":          true,
+	"env":              true,
+	"envFrom":          true,
+	"imagePullSecrets": true,
+	"initContainers":   true,
+	"labels":           true,
+	"limits":           true,
+	"metadata":         true,
+	"nodeSelector":     true,
+	"ports":            true,
+	"requests":         true,
+	"resources":        true,
+	"securityContext":  true,
+	"tolerations":      true,
+	"volumeMounts":     true,
+	"volumes":          true,
+}
+
+// canonicalSpec returns a copy of the job specification in a canonical form,
+// so specifications which mean the same result in the same hash. Quantities
+// are normalized, values which are equal to their default are removed and
+// lists of which the order has no meaning are sorted. The defaults of a Job
+// are only removed for Jobs and the default restart policy only for bare Pods,
+// the pod specs and containers are found through the kind.
+func canonicalSpec(kind WorkloadKind, spec map[string]interface{}) map[string]interface{} {
+	spec = runtime.DeepCopyJSONValue(spec).(map[string]interface{})
+
+	if kind.Kind == jobKind.Kind {
+		removeDefaults(spec, jobDefaults)
+		if _, ok := spec["parallelism"]; !ok && sameValue(spec["completions"], int64(1)) {
+			delete(spec, "completions")
+		}
+	}
+
+	if kind.Kind == podKind.Kind {
+		removeDefaults(spec, barePodDefaults)
+	}
+
+	obj := map[string]interface{}{"spec": spec}
+	for _, pod := range kind.podSpecs(obj) {
+		canonicalPodSpec(pod)
+	}
+
+	for _, container := range kind.containers(obj) {
+		canonicalContainer(container)
+	}
+
+	return pruneEmpty(spec).(map[string]interface{})
+}
+
+func canonicalPodSpec(pod map[string]interface{}) {
+	removeDefaults(pod, podDefaults)
+	normalizeQuantities(pod["overhead"])
+
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		for _, container := range mapsOf(pod[field]) {
+			canonicalContainer(container)
+		}
+	}
+
+	for _, volume := range mapsOf(pod["volumes"]) {
+		canonicalVolume(volume)
+	}
+
+	sortList(pod, "volumes", "name")
+	sortList(pod, "imagePullSecrets", "name")
+}
+
+func canonicalContainer(container map[string]interface{}) {
+	removeDefaults(container, containerDefaults)
+	if image, ok := container["image"].(string); ok && container["imagePullPolicy"] == defaultPullPolicy(image) {
+		delete(container, "imagePullPolicy")
+	}
+
+	if resources, ok := container["resources"].(map[string]interface{}); ok {
+		normalizeQuantities(resources["limits"])
+		normalizeQuantities(resources["requests"])
+	}
+
+	for _, port := range mapsOf(container["ports"]) {
+		if port["protocol"] == "TCP" {
+			delete(port, "protocol")
+		}
+	}
+
+	for _, env := range mapsOf(container["env"]) {
+		if ref, ok := nestedMap(env, "valueFrom", "fieldRef"); ok && ref["apiVersion"] == "v1" {
+			delete(ref, "apiVersion")
+		}
+	}
+
+	// environment variables can reference earlier variables, in which case
+	// their order has a meaning
+	if !referencesVariables(container["env"]) {
+		sortList(container, "env", "name")
+	}
+	sortList(container, "volumeMounts", "mountPath")
+	sortList(container, "ports", "containerPort", "protocol")
+}
+
+func canonicalVolume(volume map[string]interface{}) {
+	if dir, ok := volume["emptyDir"].(map[string]interface{}); ok {
+		if limit, ok := dir["sizeLimit"]; ok {
+			dir["sizeLimit"] = normalizeQuantity(limit)
+		}
+	}
+
+	for _, field := range []string{"configMap", "secret", "projected", "downwardAPI"} {
+		source, ok := volume[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if sameValue(source["defaultMode"], defaultMode) {
+			delete(source, "defaultMode")
+		}
+		sortList(source, "items", "key")
+
+		for _, projection := range mapsOf(source["sources"]) {
+			for _, kind := range []string{"configMap", "secret"} {
+				if p, ok := projection[kind].(map[string]interface{}); ok {
+					sortList(p, "items", "key")
+				}
+			}
+		}
+	}
+}
+
+// defaultPullPolicy returns the image pull policy the API server sets for the
+// image when it's omitted.
+func defaultPullPolicy(image string) string {
+	if strings.Contains(image, "@") {
+		return "IfNotPresent"
+	}
+
+	name := image[strings.LastIndex(image, "/")+1:]
+	if !strings.Contains(name, ":") || strings.HasSuffix(name, ":latest") {
+		return "Always"
+	}
+
+	return "IfNotPresent"
+}
+
+// referencesVariables reports if any of the environment variables uses the
+// `$(VAR)` syntax to reference another variable.
+func referencesVariables(env interface{}) bool {
+	for _, e := range mapsOf(env) {
+		if value, ok := e["value"].(string); ok && strings.Contains(value, "$(") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeQuantities replaces all quantities in the map with their canonical
+// form, so `1000m` and `1` are written the same.
+func normalizeQuantities(obj interface{}) {
+	quantities, ok := obj.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for name, value := range quantities {
+		quantities[name] = normalizeQuantity(value)
+	}
+}
+
+func normalizeQuantity(value interface{}) interface{} {
+	q, err := resource.ParseQuantity(fmt.Sprint(value))
+	if err != nil {
+		return value
+	}
+
+	return q.String()
+}
+
+func removeDefaults(obj map[string]interface{}, defaults map[string]interface{}) {
+	for field, def := range defaults {
+		if value, ok := obj[field]; ok && sameValue(value, def) {
+			delete(obj, field)
+		}
+	}
+}
+
+// sortList sorts the list in the given field of the object by the given keys
+// of its items.
+func sortList(obj map[string]interface{}, field string, keys ...string) {
+	list, ok := obj[field].([]interface{})
+	if !ok {
+		return
+	}
+
+	sortKey := func(item interface{}) string {
+		m, _ := item.(map[string]interface{})
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			// pad numbers so they sort by their value
+			if f, ok := toFloat(m[key]); ok {
+				parts = append(parts, fmt.Sprintf("%020.0f", f))
+				continue
+			}
+			parts = append(parts, fmt.Sprint(m[key]))
+		}
+		return strings.Join(parts, "/")
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return sortKey(list[i]) < sortKey(list[j])
+	})
+}
+
+// pruneEmpty removes null values and the empty values of emptyFields from the
+// object.
+func pruneEmpty(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			value = pruneEmpty(value)
+			if value == nil || (emptyFields[key] && isEmpty(value)) {
+				delete(v, key)
+				continue
+			}
+			v[key] = value
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = pruneEmpty(v[i])
+		}
+	}
+
+	return obj
+}
+
+func isEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+
+	return false
+}
+
+// sameValue compares two values from an object, where numbers are compared by
+// their value regardless of their type.
+func sameValue(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
+
+func nestedMap(obj map[string]interface{}, fields ...string) (map[string]interface{}, bool) {
+	for _, field := range fields {
+		next, ok := obj[field].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		obj = next
+	}
+
+	return obj, true
+}
+
+func mapsOf(list interface{}) []map[string]interface{} {
+	items, _ := list.([]interface{})
+	maps := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			maps = append(maps, m)
+		}
+	}
+
+	return maps
+}
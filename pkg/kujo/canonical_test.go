@@ -0,0 +1,102 @@
+package kujo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCanonicalSpec(t *testing.T) {
+	tcs := map[string]struct {
+		kind   WorkloadKind
+		spec   string
+		result string
+	}{
+		"with quantities": {
+			spec:   `{"template": {"spec": {"containers": [{"name": "a", "resources": {"limits": {"cpu": "1000m", "memory": "1024Mi"}, "requests": {"cpu": 0.5}}}]}}}`,
+			result: `{"template": {"spec": {"containers": [{"name": "a", "resources": {"limits": {"cpu": "1", "memory": "1Gi"}, "requests": {"cpu": "500m"}}}]}}}`,
+		},
+		"with defaults": {
+			spec: `{"backoffLimit": 6, "completions": 1, "template": {"metadata": {"creationTimestamp": null}, "spec": {
+				"dnsPolicy": "ClusterFirst", "schedulerName": "default-scheduler", "terminationGracePeriodSeconds": 30, "securityContext": {},
+				"containers": [{"name": "a", "image": "perl:5", "imagePullPolicy": "IfNotPresent", "terminationMessagePath": "/dev/termination-log", "terminationMessagePolicy": "File", "resources": {}, "ports": [{"containerPort": 80, "protocol": "TCP"}]}],
+				"volumes": [{"name": "config", "configMap": {"name": "config", "defaultMode": 420}}, {"name": "scratch", "emptyDir": {}}]}}}`,
+			result: `{"template": {"spec": {
+				"containers": [{"name": "a", "image": "perl:5", "ports": [{"containerPort": 80}]}],
+				"volumes": [{"name": "config", "configMap": {"name": "config"}}, {"name": "scratch", "emptyDir": {}}]}}}`,
+		},
+		"with the default restart policy of a pod": {
+			spec:   `{"template": {"spec": {"restartPolicy": "Always", "containers": [{"name": "a"}]}}}`,
+			result: `{"template": {"spec": {"restartPolicy": "Always", "containers": [{"name": "a"}]}}}`,
+		},
+		"with the default restart policy of a bare pod": {
+			kind:   podKind,
+			spec:   `{"restartPolicy": "Always", "dnsPolicy": "ClusterFirst", "containers": [{"name": "a"}]}`,
+			result: `{"containers": [{"name": "a"}]}`,
+		},
+		"with another restart policy of a bare pod": {
+			kind:   podKind,
+			spec:   `{"restartPolicy": "Never", "containers": [{"name": "a"}]}`,
+			result: `{"restartPolicy": "Never", "containers": [{"name": "a"}]}`,
+		},
+		"with values other than the defaults": {
+			spec:   `{"backoffLimit": 4, "completions": 1, "parallelism": 2, "template": {"spec": {"restartPolicy": "Never", "containers": [{"name": "a", "image": "perl", "imagePullPolicy": "IfNotPresent"}]}}}`,
+			result: `{"backoffLimit": 4, "completions": 1, "parallelism": 2, "template": {"spec": {"restartPolicy": "Never", "containers": [{"name": "a", "image": "perl", "imagePullPolicy": "IfNotPresent"}]}}}`,
+		},
+		"with unordered lists": {
+			spec: `{"template": {"spec": {
+				"containers": [{"name": "a", "env": [{"name": "B", "value": "2"}, {"name": "A", "value": "1"}], "volumeMounts": [{"name": "b", "mountPath": "/b"}, {"name": "a", "mountPath": "/a"}], "ports": [{"containerPort": 8080}, {"containerPort": 443}]}],
+				"volumes": [{"name": "b", "secret": {"secretName": "b", "items": [{"key": "y", "path": "y"}, {"key": "x", "path": "x"}]}}, {"name": "a", "emptyDir": {}}]}}}`,
+			result: `{"template": {"spec": {
+				"containers": [{"name": "a", "env": [{"name": "A", "value": "1"}, {"name": "B", "value": "2"}], "volumeMounts": [{"name": "a", "mountPath": "/a"}, {"name": "b", "mountPath": "/b"}], "ports": [{"containerPort": 443}, {"containerPort": 8080}]}],
+				"volumes": [{"name": "a", "emptyDir": {}}, {"name": "b", "secret": {"secretName": "b", "items": [{"key": "x", "path": "x"}, {"key": "y", "path": "y"}]}}]}}}`,
+		},
+		"with dependent environment variables": {
+			spec:   `{"template": {"spec": {"containers": [{"name": "a", "env": [{"name": "B", "value": "2"}, {"name": "A", "value": "$(B)"}]}]}}}`,
+			result: `{"template": {"spec": {"containers": [{"name": "a", "env": [{"name": "B", "value": "2"}, {"name": "A", "value": "$(B)"}]}]}}}`,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var spec, expected map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.spec), &spec); err != nil {
+				t.Fatalf("Expected no error parsing the spec, got '%s'", err)
+			}
+
+			if err := json.Unmarshal([]byte(tc.result), &expected); err != nil {
+				t.Fatalf("Expected no error parsing the result, got '%s'", err)
+			}
+
+			kind := tc.kind
+			if kind.Kind == "" {
+				kind = jobKind
+			}
+
+			result := canonicalSpec(kind, spec)
+			if !cmp.Equal(expected, result) {
+				t.Errorf("Expected canonical spec to match, got diff %s", cmp.Diff(expected, result))
+			}
+		})
+	}
+}
+
+func TestDefaultPullPolicy(t *testing.T) {
+	tcs := map[string]string{
+		"perl":                         "Always",
+		"perl:latest":                  "Always",
+		"perl:5":                       "IfNotPresent",
+		"localhost:5000/perl":          "Always",
+		"localhost:5000/perl:5":        "IfNotPresent",
+		"perl@sha256:0123456789abcdef": "IfNotPresent",
+	}
+
+	for image, policy := range tcs {
+		t.Run(image, func(t *testing.T) {
+			if result := defaultPullPolicy(image); result != policy {
+				t.Errorf("Expected pull policy '%s', got '%s'", policy, result)
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package kujo
 
 import (
 	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -9,18 +11,25 @@ import (
 
 // HashedConfig goes over a given set of unstructured objects and filters out
 // the ConfigMap and Secret objects. It then hashes it's content and returns a
-// map of hashes, where the key is in the `<namespace>/<name>` format.
+// map of hashes, where the key is in the `<kind>/<namespace>/<name>` format.
+// Only the content which is exposed to a Pod is hashed, changes to the
+// metadata of an object don't change its hash.
+// Next to the hash of the full object, each value of the object is hashed
+// separately with a key in the `<kind>/<namespace>/<name>/<key>` format. This
+// allows jobs which only reference a single value to only change when that
+// value changes.
 func HashedConfig(uList []unstructured.Unstructured) (map[string]string, error) {
+	return hashedConfig(uList, Options{})
+}
+
+func hashedConfig(uList []unstructured.Unstructured, opts Options) (map[string]string, error) {
 	uMap := map[string]string{}
 	for _, un := range uList {
 		switch un.GetKind() {
 		case "ConfigMap", "Secret":
-			ns := un.GetNamespace()
-			if ns == "" {
-				ns = "default"
-			}
+			ns := opts.namespace(un.GetNamespace())
 
-			key := fmt.Sprintf("%s/%s/%s", un.GetKind(), ns, un.GetName())
+			key := configKey(un.GetKind(), ns, un.GetName(), "")
 			if _, ok := uMap[key]; !ok {
 				hsh, err := hashUnstructured(un)
 				if err != nil {
@@ -28,6 +37,16 @@ func HashedConfig(uList []unstructured.Unstructured) (map[string]string, error)
 				}
 
 				uMap[key] = hsh
+
+				data, err := configData(un)
+				if err != nil {
+					return nil, err
+				}
+
+				for dataKey, value := range data {
+					valueKey := configKey(un.GetKind(), ns, un.GetName(), dataKey)
+					uMap[valueKey] = hashValue(valueKey, value)
+				}
 			}
 		}
 	}
@@ -35,11 +54,106 @@ func HashedConfig(uList []unstructured.Unstructured) (map[string]string, error)
 	return uMap, nil
 }
 
+// configKey returns the key under which the hash of a ConfigMap or Secret is
+// stored. When dataKey is set, the key of that specific value is returned.
+func configKey(kind, ns, name, dataKey string) string {
+	if dataKey == "" {
+		return fmt.Sprintf("%s/%s/%s", kind, ns, name)
+	}
+
+	return fmt.Sprintf("%s/%s/%s/%s", kind, ns, name, dataKey)
+}
+
+// hashValue returns the digest of a single value of a ConfigMap or Secret. The
+// key of the value is hashed along with it, so equal values don't share a
+// digest and the digest of a Secret value can't be matched against the digests
+// of known values.
+func hashValue(key string, value []byte) string {
+	h := sha256.New()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write(value)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// configContent is the part of a ConfigMap or Secret which is exposed to a
+// Pod. Metadata such as labels and annotations is left out, so changing it
+// doesn't change the hash of the object.
+type configContent struct {
+	Type      string            `json:"type,omitempty"`
+	Immutable bool              `json:"immutable"`
+	Data      map[string][]byte `json:"data"`
+}
+
+// hashUnstructured hashes the content of a ConfigMap or Secret.
 func hashUnstructured(obj unstructured.Unstructured) (string, error) {
-	data, err := obj.MarshalJSON()
+	content := configContent{}
+
+	var err error
+	content.Data, err = configData(obj)
+	if err != nil {
+		return "", err
+	}
+
+	content.Immutable, _, err = unstructured.NestedBool(obj.Object, "immutable")
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("%x", sha256.Sum256([]byte(data))), nil
+	if obj.GetKind() == "Secret" {
+		content.Type, _, err = unstructured.NestedString(obj.Object, "type")
+		if err != nil {
+			return "", err
+		}
+
+		if content.Type == "" {
+			content.Type = "Opaque"
+		}
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// configData returns the decoded values of a ConfigMap or Secret. The `data`
+// of a Secret and the `binaryData` of a ConfigMap are base64 decoded. The
+// `stringData` of a Secret is merged into the data, overwriting existing keys
+// like the API server does.
+func configData(obj unstructured.Unstructured) (map[string][]byte, error) {
+	data := map[string][]byte{}
+
+	fields := []struct {
+		name    string
+		encoded bool
+	}{
+		{name: "data", encoded: obj.GetKind() == "Secret"},
+		{name: "binaryData", encoded: true},
+		{name: "stringData"},
+	}
+
+	for _, field := range fields {
+		values, _, err := unstructured.NestedStringMap(obj.Object, field.name)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, value := range values {
+			if !field.encoded {
+				data[key] = []byte(value)
+				continue
+			}
+
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("could not decode key '%s' of %s '%s': %s", key, obj.GetKind(), obj.GetName(), err)
+			}
+			data[key] = decoded
+		}
+	}
+
+	return data, nil
 }
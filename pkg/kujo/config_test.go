@@ -1,6 +1,8 @@
 package kujo
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"os"
 	"testing"
 
@@ -24,20 +26,36 @@ func TestHashedConfig(t *testing.T) {
 		"with a configmap provided": {
 			fixture: "testdata/job-configmap.yaml",
 			config: map[string]string{
-				"ConfigMap/default/perl-job-config": "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config":          "830efe400087768d8c955ed99e771b742466588423af1b3aa52a4a12391ed50e",
+				"ConfigMap/default/perl-job-config/job.data": "e84fe28894bacb8bfbd336e0ad6c442ceccf3eab0cc980aab9148e33680c2c47",
 			},
 		},
 		"with a secret provided": {
 			fixture: "testdata/job-secret.yaml",
 			config: map[string]string{
-				"Secret/default/mysecret": "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret":          "3bd33833f2154454f6bc666cc37f5d49699489cc3d019cdc4748a41c39e2654b",
+				"Secret/default/mysecret/password": "149856492e62adcbd2f5dbda95a7436b10442e1f77e4a8bb238ecdd3df6eb653",
+				"Secret/default/mysecret/username": "e5553e06220e781c96808d138762dc8d92afdecb42133223078965648c94ba70",
 			},
 		},
 		"with configmap and secret provided": {
 			fixture: "testdata/full-config.yaml",
 			config: map[string]string{
-				"Secret/default/mysecret":           "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
-				"ConfigMap/default/perl-job-config": "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"Secret/default/mysecret":                    "3bd33833f2154454f6bc666cc37f5d49699489cc3d019cdc4748a41c39e2654b",
+				"Secret/default/mysecret/password":           "149856492e62adcbd2f5dbda95a7436b10442e1f77e4a8bb238ecdd3df6eb653",
+				"Secret/default/mysecret/username":           "e5553e06220e781c96808d138762dc8d92afdecb42133223078965648c94ba70",
+				"ConfigMap/default/perl-job-config":          "830efe400087768d8c955ed99e771b742466588423af1b3aa52a4a12391ed50e",
+				"ConfigMap/default/perl-job-config/job.data": "e84fe28894bacb8bfbd336e0ad6c442ceccf3eab0cc980aab9148e33680c2c47",
+			},
+		},
+		"with metadata and stringData": {
+			fixture: "testdata/config-metadata.yaml",
+			config: map[string]string{
+				"Secret/default/mysecret":                    "3bd33833f2154454f6bc666cc37f5d49699489cc3d019cdc4748a41c39e2654b",
+				"Secret/default/mysecret/password":           "149856492e62adcbd2f5dbda95a7436b10442e1f77e4a8bb238ecdd3df6eb653",
+				"Secret/default/mysecret/username":           "e5553e06220e781c96808d138762dc8d92afdecb42133223078965648c94ba70",
+				"ConfigMap/default/perl-job-config":          "830efe400087768d8c955ed99e771b742466588423af1b3aa52a4a12391ed50e",
+				"ConfigMap/default/perl-job-config/job.data": "e84fe28894bacb8bfbd336e0ad6c442ceccf3eab0cc980aab9148e33680c2c47",
 			},
 		},
 	}
@@ -71,3 +89,24 @@ func TestHashedConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestHashValue(t *testing.T) {
+	value := []byte("hunter2")
+
+	digests := map[string]bool{}
+	for _, key := range []string{
+		"Secret/default/mysecret/password",
+		"Secret/default/othersecret/password",
+		"Secret/default/mysecret/token",
+	} {
+		digests[hashValue(key, value)] = true
+	}
+
+	if len(digests) != 3 {
+		t.Errorf("Expected equal values to get a digest per key, got %d distinct digests", len(digests))
+	}
+
+	if plain := fmt.Sprintf("%x", sha256.Sum256(value)); digests[plain] {
+		t.Errorf("Expected the digest to differ from the plain hash of the value, got '%s'", plain)
+	}
+}
@@ -2,7 +2,6 @@ package kujo
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 
 	"github.com/pkg/errors"
@@ -17,45 +16,195 @@ import (
 // Once done, it replaces all the job names from the input with the newly
 // calculated job name and outputs the data byte slice filled with YAML.
 func SuffixJobs(data io.Reader) ([]byte, error) {
-	resourceList, err := ResourcesFromReader(data)
+	return Convert(data, Options{})
+}
+
+// Convert works like SuffixJobs, but allows configuring the conversion through
+// the given options.
+func Convert(data io.Reader, opts Options) ([]byte, error) {
+	if opts.PreserveFormatting {
+		return convertPreserved(data, opts)
+	}
+
+	docs, err := documentsFromReader(data)
 	if err != nil {
 		return nil, errors.Wrap(err, "Could not read the resources from input")
 	}
 
-	jobs, err := JobSlice(resourceList)
+	resourceList, err := expandLists(docs)
 	if err != nil {
-		return nil, errors.Wrap(err, "Could not filter out the jobs")
+		return nil, errors.Wrap(err, "Could not expand the lists from input")
 	}
 
-	// no jobs in the resource list, return the original
-	if len(jobs) == 0 {
+	if err := suffixResources(resourceList, opts); err != nil {
+		return nil, err
+	}
+
+	// the items of a list share their data with the list itself, renaming the
+	// items is reflected in the documents as well.
+	if opts.FlattenLists {
 		return marshalUnstructured(resourceList)
 	}
 
-	cm, err := HashedConfig(resourceList)
+	return marshalUnstructured(docs)
+}
+
+func convertPreserved(data io.Reader, opts Options) ([]byte, error) {
+	if opts.FlattenLists {
+		return nil, errors.New("Lists can not be flattened when preserving the formatting")
+	}
+
+	docs, err := documentsFromSource(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read the resources from input")
+	}
+
+	var objects []unstructured.Unstructured
+	for _, doc := range docs {
+		if doc.node != nil {
+			objects = append(objects, doc.object)
+		}
+	}
+
+	resourceList, err := expandLists(objects)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not expand the lists from input")
+	}
+
+	if err := suffixResources(resourceList, opts); err != nil {
+		return nil, err
+	}
+
+	return marshalDocuments(docs)
+}
+
+// suffixResources renames the jobs in the given list of resources to their
+// unique name and rewrites the references and placeholders for them, followed
+// by the ConfigMaps and Secrets which opted in to being suffixed. The resources
+// are updated in place.
+func suffixResources(resourceList []unstructured.Unstructured, opts Options) error {
+	renamed, err := renameJobs(resourceList, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range renamed {
+		resourceList[job.index].SetName(job.name)
+		containers := job.kind.allContainers(resourceList[job.index].Object)
+		if opts.PinImages {
+			pinImages(containers, opts.ImageLock)
+		}
+
+		if opts.InjectEnv {
+			injectEnv(job, containers)
+		}
+
+		if err := stampProvenance(&resourceList[job.index], job.kind, job.original, job.hash); err != nil {
+			return errors.Wrapf(err, "Could not annotate job '%s'", job.key)
+		}
+	}
+
+	if err := renameJobRefs(resourceList, renamed, opts); err != nil {
+		return errors.Wrap(err, "Could not rewrite the references to renamed jobs")
+	}
+
+	if err := substitutePlaceholders(resourceList, renamed, opts); err != nil {
+		return errors.Wrap(err, "Could not substitute the placeholders")
+	}
+
+	// the jobs are hashed before their references are rewritten, the hash of
+	// a ConfigMap or Secret doesn't depend on its name.
+	if err := suffixConfig(resourceList, opts); err != nil {
+		return errors.Wrap(err, "Could not suffix the ConfigMaps and Secrets")
+	}
+
+	return nil
+}
+
+// renamedJob is a job, or a resource of another workload kind, from a list of
+// resources together with its unique name.
+type renamedJob struct {
+	index     int
+	kind      WorkloadKind
+	key       string
+	namespace string
+	original  string
+	name      string
+	hash      jobHash
+}
+
+// renameJobs calculates the unique names of the jobs in the given list of
+// resources. The jobs themselves are not renamed.
+func renameJobs(resourceList []unstructured.Unstructured, opts Options) ([]renamedJob, error) {
+	var jobs []unstructured.Unstructured
+	for _, rs := range resourceList {
+		if isWorkloadResource(rs, opts) {
+			jobs = append(jobs, rs)
+		}
+	}
+
+	// no jobs in the resource list, leave the original
+	if len(jobs) == 0 {
+		if opts.SetNamespace {
+			setNamespaces(resourceList, opts)
+		}
+		return nil, nil
+	}
+
+	cm, err := hashedConfig(resourceList, opts)
 	if err != nil {
 		return nil, errors.Wrap(err, "Could not calculate the config hashes")
 	}
 
-	jobHashes, err := HashedJobs(jobs, cm)
+	resources, err := hashedResources(resourceList, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not calculate the resource hashes")
+	}
+
+	for key, digest := range resources {
+		cm[key] = digest
+	}
+
+	// the namespaces are set after hashing the resources, which tells custom
+	// resources without a namespace apart
+	if opts.SetNamespace {
+		setNamespaces(resourceList, opts)
+	}
+
+	jobHashes, err := hashJobs(jobs, cm, opts)
 	if err != nil {
 		return nil, errors.Wrap(err, "Could not calculate job hashes")
 	}
-	for i, rs := range resourceList {
-		if isJobResource(rs) {
-			ns := rs.GetNamespace()
-			if ns == "" {
-				ns = "default"
-			}
 
-			key := fmt.Sprintf("%s/%s", ns, rs.GetName())
+	var renamed []renamedJob
+	for i, rs := range resourceList {
+		if kind, ok := workloadKind(rs, opts); ok && isOptedIn(rs) {
+			ns := opts.namespace(rs.GetNamespace())
+			key := workloadKey(rs, kind, ns)
 			if hash, ok := jobHashes[key]; ok {
-				resourceList[i].SetName(fmt.Sprintf("%s-%s", rs.GetName(), hash))
+				if err := checkReferences(key, hash, opts); err != nil {
+					return nil, err
+				}
+
+				name, err := jobName(rs, ns, hash, opts)
+				if err != nil {
+					return nil, errors.Wrapf(err, "Could not rename job '%s'", key)
+				}
+
+				renamed = append(renamed, renamedJob{
+					index:     i,
+					kind:      kind,
+					key:       key,
+					namespace: ns,
+					original:  rs.GetName(),
+					name:      name,
+					hash:      hash,
+				})
 			}
 		}
 	}
 
-	return marshalUnstructured(resourceList)
+	return renamed, nil
 }
 
 func marshalUnstructured(resourceList []unstructured.Unstructured) ([]byte, error) {
@@ -7,6 +7,75 @@ import (
 )
 
 func TestConvert(t *testing.T) {
+	tcs := map[string]struct {
+		input  string
+		output string
+		opts   Options
+	}{
+		"with separate documents": {
+			input:  "testdata/convert-input.yaml",
+			output: "testdata/convert-output.yaml",
+		},
+		"with a list": {
+			input:  "testdata/list-input.yaml",
+			output: "testdata/list-output.yaml",
+		},
+		"with a flattened list": {
+			input:  "testdata/list-input.yaml",
+			output: "testdata/list-flat-output.yaml",
+			opts: Options{
+				FlattenLists: true,
+			},
+		},
+		"with preserved formatting": {
+			input:  "testdata/preserve-input.yaml",
+			output: "testdata/preserve-output.yaml",
+			opts: Options{
+				PreserveFormatting: true,
+			},
+		},
+		"with a namespace": {
+			input:  "testdata/namespace-input.yaml",
+			output: "testdata/namespace-output.yaml",
+			opts: Options{
+				Namespace:    "migrations",
+				SetNamespace: true,
+			},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			input, err := os.Open(tc.input)
+			if err != nil {
+				t.Errorf("Expected no error opening the input file, got '%s'", err)
+			}
+			defer input.Close()
+
+			generated, err := Convert(input, tc.opts)
+			if err != nil {
+				t.Errorf("Did not expect error, got '%s'", err)
+			}
+
+			output, err := os.Open(tc.output)
+			if err != nil {
+				t.Errorf("Expected no error opening the output file, got '%s'", err)
+			}
+			defer output.Close()
+
+			fixture, err := ioutil.ReadAll(output)
+			if err != nil {
+				t.Errorf("Did not expect error, got '%s'", err)
+			}
+
+			if string(fixture) != string(generated) {
+				t.Errorf("Expected generated output\n%s\nto match fixture\n%s", string(generated), string(fixture))
+			}
+		})
+	}
+}
+
+func TestSuffixJobs(t *testing.T) {
 	input, err := os.Open("testdata/convert-input.yaml")
 	if err != nil {
 		t.Errorf("Expected no error opening the input file, got '%s'", err)
@@ -18,13 +87,7 @@ func TestConvert(t *testing.T) {
 		t.Errorf("Did not expect error, got '%s'", err)
 	}
 
-	output, err := os.Open("testdata/convert-output.yaml")
-	if err != nil {
-		t.Errorf("Expected no error opening the input file, got '%s'", err)
-	}
-	defer output.Close()
-
-	fixture, err := ioutil.ReadAll(output)
+	fixture, err := ioutil.ReadFile("testdata/convert-output.yaml")
 	if err != nil {
 		t.Errorf("Did not expect error, got '%s'", err)
 	}
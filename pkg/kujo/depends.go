@@ -0,0 +1,188 @@
+package kujo
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// dependsOnAnnKey is the annotation which lists the resources a job depends on
+// next to the ConfigMaps and Secrets it references.
+const dependsOnAnnKey = annKey + "/depends-on"
+
+// resourceKey returns the key of a resource in the map of hashed resources.
+// Resources of a named API group hold the group in their kind, cluster scoped
+// resources have no namespace.
+func resourceKey(group, kind, ns, name string) string {
+	if group != "" {
+		kind = kind + "." + group
+	}
+
+	if ns == "" {
+		return fmt.Sprintf("%s/%s", kind, name)
+	}
+
+	return fmt.Sprintf("%s/%s/%s", kind, ns, name)
+}
+
+// hashedResources returns the content hashes of all resources in the list,
+// except for ConfigMaps and Secrets which are hashed by hashedConfig. Custom
+// resources without a namespace are taken as cluster scoped, kujo can't know
+// the scope of their kind.
+func hashedResources(uList []unstructured.Unstructured, opts Options) (map[string]string, error) {
+	uMap := map[string]string{}
+	for _, un := range uList {
+		kind := un.GetKind()
+		if kind == "" || kind == "ConfigMap" || kind == "Secret" {
+			continue
+		}
+
+		group := un.GroupVersionKind().Group
+		ns := opts.namespace(un.GetNamespace())
+		if clusterScopedKinds[kind] || (isCustomGroup(group) && un.GetNamespace() == "") {
+			ns = ""
+		}
+
+		key := resourceKey(group, kind, ns, un.GetName())
+		if _, ok := uMap[key]; ok {
+			continue
+		}
+
+		hsh, err := hashResource(un)
+		if err != nil {
+			return nil, err
+		}
+		uMap[key] = hsh
+	}
+
+	return uMap, nil
+}
+
+// hashResource hashes the content of a resource. The metadata, status and API
+// version are left out, so only changes to the resource itself change the
+// hash.
+func hashResource(un unstructured.Unstructured) (string, error) {
+	content := runtime.DeepCopyJSON(un.Object)
+	for _, field := range []string{"apiVersion", "kind", "metadata", "status"} {
+		delete(content, field)
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// dependencyRefs returns the references to the resources listed in the
+// depends-on annotation of the job. Resources are written as `Kind/name` or
+// `group/Kind/name` and are looked up in the namespace of the job, unless they
+// are cluster scoped. A custom resource which isn't part of that namespace is
+// looked up among the cluster scoped resources. A resource without a group
+// matches a resource of that kind in any group of the given resources, as long
+// as there's only one.
+func dependencyRefs(job unstructured.Unstructured, ns string, resources map[string]string) ([]Reference, error) {
+	var refs []Reference
+	for _, dependency := range annotationList(job.GetAnnotations(), dependsOnAnnKey) {
+		var group, kind, name string
+		parts := strings.Split(dependency, "/")
+		switch len(parts) {
+		case 2:
+			kind, name = parts[0], parts[1]
+		case 3:
+			group, kind, name = parts[0], parts[1], parts[2]
+		default:
+			return nil, fmt.Errorf("the dependency '%s' is not written as Kind/name or group/Kind/name", dependency)
+		}
+
+		if kind == "" || name == "" {
+			return nil, fmt.Errorf("the dependency '%s' is not written as Kind/name or group/Kind/name", dependency)
+		}
+
+		refNs := ns
+		if clusterScopedKinds[kind] {
+			refNs = ""
+		}
+
+		key, err := dependencyKey(group, kind, refNs, name, resources)
+		if err != nil {
+			return nil, fmt.Errorf("the dependency '%s' is ambiguous: %s", dependency, err)
+		}
+
+		if _, ok := resources[key]; !ok && refNs != "" && (group == "" || isCustomGroup(group)) {
+			clusterKey, err := dependencyKey(group, kind, "", name, resources)
+			if err != nil {
+				return nil, fmt.Errorf("the dependency '%s' is ambiguous: %s", dependency, err)
+			}
+
+			if _, ok := resources[clusterKey]; ok {
+				key = clusterKey
+			}
+		}
+
+		refs = append(refs, Reference{Key: key})
+	}
+
+	return refs, nil
+}
+
+// dependencyKey returns the key of a dependency in the given namespace. Without
+// a group, the key of the resource of the kind in any API group is returned.
+func dependencyKey(group, kind, ns, name string, resources map[string]string) (string, error) {
+	key := resourceKey(group, kind, ns, name)
+	if group != "" {
+		return key, nil
+	}
+
+	return groupedResourceKey(key, kind, ns, name, resources)
+}
+
+// isCustomGroup returns whether the API group belongs to custom resources.
+// The groups of built-in resources either have no dot or end in k8s.io.
+func isCustomGroup(group string) bool {
+	return strings.Contains(group, ".") && !strings.HasSuffix(group, ".k8s.io")
+}
+
+// groupedResourceKey returns the key of the resource of the kind in any API
+// group when the key without a group is not part of the resources. The key
+// without a group is returned when no resource matches, an error when more
+// than one does.
+func groupedResourceKey(key, kind, ns, name string, resources map[string]string) (string, error) {
+	if _, ok := resources[key]; ok {
+		return key, nil
+	}
+
+	suffix := "/" + name
+	if ns != "" {
+		suffix = "/" + ns + suffix
+	}
+
+	var matches []string
+	for candidate := range resources {
+		if !strings.HasPrefix(candidate, kind+".") || !strings.HasSuffix(candidate, suffix) {
+			continue
+		}
+
+		// the group can't hold a slash, which rules out the keys of values of
+		// ConfigMaps and Secrets and resources in other namespaces
+		if group := candidate[len(kind)+1 : len(candidate)-len(suffix)]; group != "" && !strings.Contains(group, "/") {
+			matches = append(matches, candidate)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return key, nil
+	case 1:
+		return matches[0], nil
+	}
+
+	sort.Strings(matches)
+	return "", fmt.Errorf("it matches %s, add the group", strings.Join(matches, ", "))
+}
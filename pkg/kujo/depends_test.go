@@ -0,0 +1,206 @@
+package kujo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDependencyRefs(t *testing.T) {
+	resources := map[string]string{
+		"RoleBinding.rbac.authorization.k8s.io/migrations/migrate": "abc",
+		"Deployment.apps/migrations/api":                           "def",
+		"Deployment.extensions/migrations/api":                     "ghi",
+		"Deployment.apps/other/worker":                             "jkl",
+		"ServiceAccount/migrations/migrate":                        "mno",
+		"Widget.example.com/migrate":                               "pqr",
+	}
+
+	tcs := map[string]struct {
+		annotation string
+		refs       []Reference
+		err        bool
+	}{
+		"without dependencies": {},
+		"with a core kind": {
+			annotation: "ServiceAccount/migrate",
+			refs:       []Reference{{Key: "ServiceAccount/migrations/migrate"}},
+		},
+		"with a group": {
+			annotation: "example.com/Database/users",
+			refs:       []Reference{{Key: "Database.example.com/migrations/users"}},
+		},
+		"with a cluster scoped kind": {
+			annotation: "rbac.authorization.k8s.io/ClusterRole/migrate",
+			refs:       []Reference{{Key: "ClusterRole.rbac.authorization.k8s.io/migrate"}},
+		},
+		"with a ConfigMap": {
+			annotation: "ConfigMap/settings",
+			refs:       []Reference{{Key: "ConfigMap/migrations/settings"}},
+		},
+		"with a kind of a named group": {
+			annotation: "RoleBinding/migrate",
+			refs:       []Reference{{Key: "RoleBinding.rbac.authorization.k8s.io/migrations/migrate"}},
+		},
+		"with a kind in multiple groups": {
+			annotation: "Deployment/api",
+			err:        true,
+		},
+		"with a kind in multiple groups and a group": {
+			annotation: "apps/Deployment/api",
+			refs:       []Reference{{Key: "Deployment.apps/migrations/api"}},
+		},
+		"with a kind of a named group in another namespace": {
+			annotation: "Deployment/worker",
+			refs:       []Reference{{Key: "Deployment/migrations/worker"}},
+		},
+		"with a cluster scoped custom resource": {
+			annotation: "Widget/migrate",
+			refs:       []Reference{{Key: "Widget.example.com/migrate"}},
+		},
+		"with a cluster scoped custom resource and a group": {
+			annotation: "example.com/Widget/migrate",
+			refs:       []Reference{{Key: "Widget.example.com/migrate"}},
+		},
+		"with a version": {
+			annotation: "apps/v1/Deployment/api",
+			err:        true,
+		},
+		"without a name": {
+			annotation: "ServiceAccount/",
+			err:        true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			job := unstructured.Unstructured{Object: map[string]interface{}{}}
+			job.SetAnnotations(map[string]string{"kujo.sphc.io/depends-on": tc.annotation})
+
+			refs, err := dependencyRefs(job, "migrations", resources)
+			if tc.err != (err != nil) {
+				t.Errorf("Expected error to be %t, got '%v'", tc.err, err)
+			}
+
+			// we've got an error, don't run further tests
+			if err != nil {
+				return
+			}
+
+			if !cmp.Equal(tc.refs, refs) {
+				t.Errorf("Expected references to match, got diff %s", cmp.Diff(tc.refs, refs))
+			}
+		})
+	}
+}
+
+func TestHashedResources(t *testing.T) {
+	resource := func(apiVersion, kind, ns string) unstructured.Unstructured {
+		un := unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{"size": "1"}}}
+		un.SetAPIVersion(apiVersion)
+		un.SetKind(kind)
+		un.SetName("migrate")
+		un.SetNamespace(ns)
+		return un
+	}
+
+	tcs := map[string]struct {
+		resource unstructured.Unstructured
+		key      string
+	}{
+		"with a namespaced kind": {
+			resource: resource("v1", "ServiceAccount", ""),
+			key:      "ServiceAccount/migrations/migrate",
+		},
+		"with a built-in kind of a named group": {
+			resource: resource("rbac.authorization.k8s.io/v1", "RoleBinding", ""),
+			key:      "RoleBinding.rbac.authorization.k8s.io/migrations/migrate",
+		},
+		"with a cluster scoped kind": {
+			resource: resource("rbac.authorization.k8s.io/v1", "ClusterRole", "other"),
+			key:      "ClusterRole.rbac.authorization.k8s.io/migrate",
+		},
+		"with a custom resource in a namespace": {
+			resource: resource("example.com/v1", "Widget", "other"),
+			key:      "Widget.example.com/other/migrate",
+		},
+		"with a custom resource without a namespace": {
+			resource: resource("example.com/v1", "Widget", ""),
+			key:      "Widget.example.com/migrate",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			resources, err := hashedResources([]unstructured.Unstructured{tc.resource}, Options{Namespace: "migrations"})
+			if err != nil {
+				t.Fatalf("Expected no error hashing the resources, got '%s'", err)
+			}
+
+			if _, ok := resources[tc.key]; !ok || len(resources) != 1 {
+				t.Errorf("Expected the resource to be hashed as '%s', got %v", tc.key, resources)
+			}
+		})
+	}
+}
+
+func TestExplainDependencies(t *testing.T) {
+	input, err := ioutil.ReadFile("testdata/depends-input.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error reading the input file, got '%s'", err)
+	}
+
+	explain := func(data []byte) Explanation {
+		explanations, err := Explain(bytes.NewReader(data), Options{})
+		if err != nil {
+			t.Fatalf("Expected no error explaining the input, got '%s'", err)
+		}
+
+		return explanations[0]
+	}
+
+	original := explain(input)
+
+	var keys []string
+	for _, input := range original.Inputs {
+		keys = append(keys, input.Key)
+	}
+
+	expected := []string{"ServiceAccount/migrations/migrate", "ClusterRole.rbac.authorization.k8s.io/migrate", "Database.example.com/migrations/users", "RoleBinding.rbac.authorization.k8s.io/migrations/migrate"}
+	if !cmp.Equal(expected, keys) {
+		t.Errorf("Expected inputs to match, got diff %s", cmp.Diff(expected, keys))
+	}
+
+	unresolved := []Reference{{Key: "PersistentVolumeClaim/migrations/data"}}
+	if !cmp.Equal(unresolved, original.Unresolved) {
+		t.Errorf("Expected unresolved references to match, got diff %s", cmp.Diff(unresolved, original.Unresolved))
+	}
+
+	tcs := map[string]struct {
+		from, to string
+		changed  bool
+	}{
+		"with a changed resource": {
+			from:    "engine: postgres",
+			to:      "engine: mysql",
+			changed: true,
+		},
+		"with changed metadata": {
+			from:    "  name: users\n",
+			to:      "  name: users\n  labels:\n    team: data\n",
+			changed: false,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			updated := explain(bytes.Replace(input, []byte(tc.from), []byte(tc.to), 1))
+			if changed := updated.Hash != original.Hash; changed != tc.changed {
+				t.Errorf("Expected the hash to change to be %t", tc.changed)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package kujo
+
+// injectEnv adds the hash, unique name and original name of the job as the
+// KUJO_HASH, KUJO_JOB_NAME and KUJO_BASE_NAME environment variables to the
+// given containers of the job. Variables which are already defined by a
+// container are left as they are. The job is hashed before the variables are
+// injected, so they don't change its hash.
+func injectEnv(job renamedJob, containers []map[string]interface{}) {
+	variables := []struct {
+		name  string
+		value string
+	}{
+		{name: "KUJO_HASH", value: job.hash.Full},
+		{name: "KUJO_JOB_NAME", value: job.name},
+		{name: "KUJO_BASE_NAME", value: job.original},
+	}
+
+	for _, container := range containers {
+		env, _ := container["env"].([]interface{})
+
+		defined := map[string]bool{}
+		for _, variable := range mapsOf(env) {
+			if name, ok := variable["name"].(string); ok {
+				defined[name] = true
+			}
+		}
+
+		for _, variable := range variables {
+			if !defined[variable.name] {
+				env = append(env, map[string]interface{}{"name": variable.name, "value": variable.value})
+			}
+		}
+
+		container["env"] = env
+	}
+}
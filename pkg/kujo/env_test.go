@@ -0,0 +1,167 @@
+package kujo
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInjectEnv(t *testing.T) {
+	input := []byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  namespace: migrations
+  annotations:
+    kujo.sphc.io: "true"
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: wait
+        image: busybox
+      containers:
+      - name: migrate
+        image: migrate
+        env:
+        - name: KUJO_BASE_NAME
+          value: custom
+      restartPolicy: Never
+`)
+
+	plain, err := Convert(bytes.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Expected no error converting the input, got '%s'", err)
+	}
+
+	injected, err := Convert(bytes.NewReader(input), Options{InjectEnv: true})
+	if err != nil {
+		t.Fatalf("Expected no error converting the input, got '%s'", err)
+	}
+
+	plainResources, err := ResourcesFromReader(bytes.NewReader(plain))
+	if err != nil {
+		t.Fatalf("Expected no error reading the output, got '%s'", err)
+	}
+
+	resources, err := ResourcesFromReader(bytes.NewReader(injected))
+	if err != nil {
+		t.Fatalf("Expected no error reading the output, got '%s'", err)
+	}
+
+	job := resources[0]
+	if job.GetName() != plainResources[0].GetName() {
+		t.Errorf("Expected the name to be '%s', got '%s'", plainResources[0].GetName(), job.GetName())
+	}
+
+	hash := job.GetAnnotations()[hashAnnKey]
+	tcs := map[string]struct {
+		container string
+		env       map[string]string
+	}{
+		"with an init container": {
+			container: "initContainers",
+			env: map[string]string{
+				"KUJO_HASH":      hash,
+				"KUJO_JOB_NAME":  job.GetName(),
+				"KUJO_BASE_NAME": "migrate",
+			},
+		},
+		"with a defined variable": {
+			container: "containers",
+			env: map[string]string{
+				"KUJO_HASH":      hash,
+				"KUJO_JOB_NAME":  job.GetName(),
+				"KUJO_BASE_NAME": "custom",
+			},
+		},
+	}
+
+	pod, _ := nestedMap(job.Object, "spec", "template", "spec")
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			env := map[string]string{}
+			for _, container := range mapsOf(pod[tc.container]) {
+				for _, variable := range mapsOf(container["env"]) {
+					env[variable["name"].(string)], _ = variable["value"].(string)
+				}
+			}
+
+			if !cmp.Equal(tc.env, env) {
+				t.Errorf("Expected env to match, got diff %s", cmp.Diff(tc.env, env))
+			}
+		})
+	}
+}
+
+func TestInjectEnvPreserved(t *testing.T) {
+	input := `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  labels: {app: migrate}
+  annotations: {kujo.sphc.io: "true"}
+spec:
+  template:
+    metadata:
+      labels: {app: migrate}
+    spec:
+      containers:
+        - name: migrate
+          image: migrate
+          env:
+            - name: LOG_LEVEL
+              valueFrom: {configMapKeyRef: {name: settings, key: level}}
+
+          # the arguments
+          args: ["--all"]
+      restartPolicy: Never
+`
+
+	output, err := Convert(strings.NewReader(input), Options{InjectEnv: true, PreserveFormatting: true})
+	if err != nil {
+		t.Fatalf("Expected no error converting the input, got '%s'", err)
+	}
+
+	resources, err := ResourcesFromReader(bytes.NewReader(output))
+	if err != nil {
+		t.Fatalf("Expected no error reading the output, got '%s'", err)
+	}
+
+	name, hash := resources[0].GetName(), resources[0].GetAnnotations()[hashAnnKey]
+	expected := fmt.Sprintf(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: %[1]s
+  labels: {"kujo.sphc.io/base-name": "migrate", app: migrate}
+  annotations: {"kujo.sphc.io/hash": "%[2]s", "kujo.sphc.io/inputs": "[]", "kujo.sphc.io/original-name": "migrate", kujo.sphc.io: "true"}
+spec:
+  template:
+    metadata:
+      labels: {"kujo.sphc.io/base-name": "migrate", app: migrate}
+    spec:
+      containers:
+        - name: migrate
+          image: migrate
+          env:
+            - name: LOG_LEVEL
+              valueFrom: {configMapKeyRef: {name: settings, key: level}}
+            - name: KUJO_HASH
+              value: %[2]s
+            - name: KUJO_JOB_NAME
+              value: %[1]s
+            - name: KUJO_BASE_NAME
+              value: migrate
+
+          # the arguments
+          args: ["--all"]
+      restartPolicy: Never
+`, name, hash)
+
+	if string(output) != expected {
+		t.Errorf("Expected output\n%s\ngot\n%s", expected, string(output))
+	}
+}
@@ -0,0 +1,127 @@
+package kujo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// hashExcludeAnnKey is the annotation which lists the fields of a job which
+// are excluded from its hash.
+const hashExcludeAnnKey = annKey + "/hash-exclude"
+
+// fieldPath parses a path to a field of a job. Paths are either JSON pointers,
+// like `/spec/template/metadata/labels/build-id`, or dotted paths, like
+// `spec.ttlSecondsAfterFinished`. Keys which contain a dot can only be used in
+// a JSON pointer.
+func fieldPath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+
+	var segments []string
+	if strings.HasPrefix(path, "/") {
+		for _, segment := range strings.Split(path[1:], "/") {
+			segment = strings.Replace(segment, "~1", "/", -1)
+			segments = append(segments, strings.Replace(segment, "~0", "~", -1))
+		}
+	} else {
+		segments = strings.Split(path, ".")
+	}
+
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("the field path '%s' has an empty segment", path)
+		}
+	}
+
+	return segments, nil
+}
+
+// excludeFields returns a copy of the job specification without the fields
+// at the given paths. The paths are relative to the job and have to start with
+// `spec`. Lists are traversed for all their items, unless the path holds the
+// index of a single item.
+func excludeFields(spec map[string]interface{}, paths []string) (map[string]interface{}, error) {
+	if len(paths) == 0 {
+		return spec, nil
+	}
+
+	spec = runtime.DeepCopyJSONValue(spec).(map[string]interface{})
+	for _, path := range paths {
+		segments, err := fieldPath(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(segments) < 2 || segments[0] != "spec" {
+			return nil, fmt.Errorf("the field path '%s' does not point to a field of the job spec", path)
+		}
+
+		removeField(spec, segments[1:])
+	}
+
+	return spec, nil
+}
+
+// removeField removes the field at the path from the object and returns the
+// updated object.
+func removeField(obj interface{}, segments []string) interface{} {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		value, ok := v[segments[0]]
+		if !ok {
+			return v
+		}
+
+		if len(segments) == 1 {
+			delete(v, segments[0])
+		} else {
+			v[segments[0]] = removeField(value, segments[1:])
+		}
+
+		return v
+	case []interface{}:
+		if segments[0] == "*" {
+			segments = segments[1:]
+		} else if i, err := strconv.Atoi(segments[0]); err == nil {
+			if i < 0 || i >= len(v) {
+				return v
+			}
+
+			if len(segments) == 1 {
+				return append(v[:i:i], v[i+1:]...)
+			}
+
+			v[i] = removeField(v[i], segments[1:])
+			return v
+		}
+
+		if len(segments) == 0 {
+			return []interface{}{}
+		}
+
+		for i := range v {
+			v[i] = removeField(v[i], segments)
+		}
+
+		return v
+	}
+
+	return obj
+}
+
+// annotationList returns the values of an annotation which holds a list
+// separated by commas or new lines.
+func annotationList(annotations map[string]string, key string) []string {
+	var values []string
+	for _, value := range strings.FieldsFunc(annotations[key], func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
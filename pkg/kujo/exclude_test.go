@@ -0,0 +1,142 @@
+package kujo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestExcludeFields(t *testing.T) {
+	spec := `{"ttlSecondsAfterFinished": 100, "activeDeadlineSeconds": 60, "template": {
+		"metadata": {"labels": {"app": "migrate", "build-id": "42", "app.kubernetes.io/version": "1"}},
+		"spec": {"containers": [{"name": "a", "image": "a:1", "args": ["--verbose"]}, {"name": "b", "image": "b:1"}]}}}`
+
+	tcs := map[string]struct {
+		paths  []string
+		result string
+		err    bool
+	}{
+		"without paths": {
+			result: spec,
+		},
+		"with dotted paths": {
+			paths: []string{"spec.ttlSecondsAfterFinished", "spec.template.metadata.labels.build-id"},
+			result: `{"activeDeadlineSeconds": 60, "template": {
+				"metadata": {"labels": {"app": "migrate", "app.kubernetes.io/version": "1"}},
+				"spec": {"containers": [{"name": "a", "image": "a:1", "args": ["--verbose"]}, {"name": "b", "image": "b:1"}]}}}`,
+		},
+		"with JSON pointers": {
+			paths: []string{"/spec/activeDeadlineSeconds", "/spec/template/metadata/labels/app.kubernetes.io~1version"},
+			result: `{"ttlSecondsAfterFinished": 100, "template": {
+				"metadata": {"labels": {"app": "migrate", "build-id": "42"}},
+				"spec": {"containers": [{"name": "a", "image": "a:1", "args": ["--verbose"]}, {"name": "b", "image": "b:1"}]}}}`,
+		},
+		"with a field of all list items": {
+			paths: []string{"spec.template.spec.containers.image"},
+			result: `{"ttlSecondsAfterFinished": 100, "activeDeadlineSeconds": 60, "template": {
+				"metadata": {"labels": {"app": "migrate", "build-id": "42", "app.kubernetes.io/version": "1"}},
+				"spec": {"containers": [{"name": "a", "args": ["--verbose"]}, {"name": "b"}]}}}`,
+		},
+		"with a list index": {
+			paths: []string{"/spec/template/spec/containers/0/args/0", "/spec/template/spec/containers/1"},
+			result: `{"ttlSecondsAfterFinished": 100, "activeDeadlineSeconds": 60, "template": {
+				"metadata": {"labels": {"app": "migrate", "build-id": "42", "app.kubernetes.io/version": "1"}},
+				"spec": {"containers": [{"name": "a", "image": "a:1", "args": []}]}}}`,
+		},
+		"with a missing field": {
+			paths:  []string{"spec.template.spec.volumes.name", "/spec/template/spec/containers/5"},
+			result: spec,
+		},
+		"with a path outside of the spec": {
+			paths: []string{"metadata.labels"},
+			err:   true,
+		},
+		"with an empty segment": {
+			paths: []string{"spec..template"},
+			err:   true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var input map[string]interface{}
+			if err := json.Unmarshal([]byte(spec), &input); err != nil {
+				t.Fatalf("Expected no error parsing the spec, got '%s'", err)
+			}
+
+			result, err := excludeFields(input, tc.paths)
+			if tc.err != (err != nil) {
+				t.Errorf("Expected error to be %t, got '%v'", tc.err, err)
+			}
+
+			// we've got an error, don't run further tests
+			if err != nil {
+				return
+			}
+
+			var expected map[string]interface{}
+			if err := json.Unmarshal([]byte(tc.result), &expected); err != nil {
+				t.Fatalf("Expected no error parsing the result, got '%s'", err)
+			}
+
+			if !cmp.Equal(expected, result) {
+				t.Errorf("Expected spec to match, got diff %s", cmp.Diff(expected, result))
+			}
+		})
+	}
+}
+
+func TestHashJobsExcludedFields(t *testing.T) {
+	job := func(ttl int64, annotations map[string]string) unstructured.Unstructured {
+		un := unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "Job",
+			"metadata": map[string]interface{}{
+				"name": "foo",
+			},
+			"spec": map[string]interface{}{
+				"ttlSecondsAfterFinished": ttl,
+			},
+		}}
+		un.SetAnnotations(annotations)
+		return un
+	}
+
+	tcs := map[string]struct {
+		annotations map[string]string
+		opts        Options
+		equal       bool
+	}{
+		"without exclusions": {
+			equal: false,
+		},
+		"with an excluded field in the options": {
+			opts:  Options{HashExclude: []string{"spec.ttlSecondsAfterFinished"}},
+			equal: true,
+		},
+		"with an excluded field in the annotation": {
+			annotations: map[string]string{"kujo.sphc.io/hash-exclude": "spec.activeDeadlineSeconds, /spec/ttlSecondsAfterFinished"},
+			equal:       true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			short, err := hashJobs([]unstructured.Unstructured{job(100, tc.annotations)}, nil, tc.opts)
+			if err != nil {
+				t.Fatalf("Expected no error hashing the jobs, got '%s'", err)
+			}
+
+			long, err := hashJobs([]unstructured.Unstructured{job(3600, tc.annotations)}, nil, tc.opts)
+			if err != nil {
+				t.Fatalf("Expected no error hashing the jobs, got '%s'", err)
+			}
+
+			if equal := short["default/foo"].Full == long["default/foo"].Full; equal != tc.equal {
+				t.Errorf("Expected the hashes to be equal to be %t, got '%s' and '%s'", tc.equal, short["default/foo"].Short, long["default/foo"].Short)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package kujo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Explanation describes how the unique name of a job was derived.
+type Explanation struct {
+	// Kind is the kind of the renamed resource. It's empty for Jobs.
+	Kind string `json:"kind,omitempty"`
+
+	// Namespace is the resolved namespace of the job.
+	Namespace string `json:"namespace"`
+
+	// Name is the original name of the job.
+	Name string `json:"name"`
+
+	// UniqueName is the name the job is renamed to.
+	UniqueName string `json:"uniqueName"`
+
+	// Hash is the full hash of the job.
+	Hash string `json:"hash"`
+
+	// Spec is the digest of the job specification.
+	Spec string `json:"spec"`
+
+	// Inputs are the ConfigMaps and Secrets, or single values of them, which
+	// contributed to the hash in the order they were hashed. The digests of
+	// Secrets are redacted.
+	Inputs []HashInput `json:"inputs"`
+
+	// Unresolved are the references to ConfigMaps and Secrets which are not
+	// part of the input and did not contribute to the hash.
+	Unresolved []Reference `json:"unresolved"`
+
+	// secrets holds the digests of the Secret inputs by their key, so
+	// explanations can be compared without exposing them.
+	secrets map[string]string
+}
+
+// Difference describes why the unique name of a job differs between two
+// inputs.
+type Difference struct {
+	// Kind is the kind of the renamed resource. It's empty for Jobs.
+	Kind string `json:"kind,omitempty"`
+
+	// Namespace is the resolved namespace of the job.
+	Namespace string `json:"namespace"`
+
+	// Name is the original name of the job.
+	Name string `json:"name"`
+
+	// From is the unique name of the job in the old input. It's empty when
+	// the job was added.
+	From string `json:"from"`
+
+	// To is the unique name of the job in the new input. It's empty when the
+	// job was removed.
+	To string `json:"to"`
+
+	// Changes are the parts of the hash which changed.
+	Changes []Change `json:"changes"`
+}
+
+// Change is a single part of the hash of a job which differs between two
+// inputs. The job specification is reported with the `spec` key, unresolved
+// references have no digest.
+type Change struct {
+	Key  string `json:"key"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// specChangeKey is the key of the change to the job specification.
+const specChangeKey = "spec"
+
+// redactedDigest replaces the digest of a Secret, or a single value of it, in
+// explanations and their differences.
+const redactedDigest = "redacted"
+
+// Explain calculates the unique names of all jobs in the input like Convert,
+// but returns how each name was derived instead of the converted resources.
+func Explain(data io.Reader, opts Options) ([]Explanation, error) {
+	docs, err := documentsFromReader(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not read the resources from input")
+	}
+
+	resourceList, err := expandLists(docs)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not expand the lists from input")
+	}
+
+	renamed, err := renameJobs(resourceList, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	explanations := []Explanation{}
+	for _, job := range renamed {
+		explanations = append(explanations, jobExplanation(job))
+	}
+
+	return explanations, nil
+}
+
+// jobExplanation returns the explanation of a renamed job. The digests of Secret
+// inputs are kept out of the exported fields.
+func jobExplanation(job renamedJob) Explanation {
+	var kind string
+	if job.kind.Kind != jobKind.Kind {
+		kind = job.kind.Kind
+	}
+
+	e := Explanation{
+		Kind:       kind,
+		Namespace:  job.namespace,
+		Name:       job.original,
+		UniqueName: job.name,
+		Hash:       job.hash.Full,
+		Spec:       job.hash.Spec,
+		Inputs:     []HashInput{},
+		Unresolved: job.hash.Unresolved,
+	}
+
+	for _, input := range job.hash.Inputs {
+		if strings.HasPrefix(input.Key, "Secret/") {
+			if e.secrets == nil {
+				e.secrets = map[string]string{}
+			}
+			e.secrets[input.Key] = input.Digest
+			input.Digest = redactedDigest
+		}
+		e.Inputs = append(e.Inputs, input)
+	}
+
+	return e
+}
+
+// Compare matches the jobs of two explanations by their kind, namespace and
+// original name and returns the differences for all jobs which got a different
+// unique name.
+func Compare(from, to []Explanation) []Difference {
+	key := func(e Explanation) string {
+		return e.Kind + "/" + e.Namespace + "/" + e.Name
+	}
+
+	fromJobs := map[string]Explanation{}
+	for _, e := range from {
+		fromJobs[key(e)] = e
+	}
+
+	toJobs := map[string]Explanation{}
+	for _, e := range to {
+		toJobs[key(e)] = e
+	}
+
+	differences := []Difference{}
+	for _, e := range from {
+		if _, ok := toJobs[key(e)]; !ok {
+			differences = append(differences, Difference{Kind: e.Kind, Namespace: e.Namespace, Name: e.Name, From: e.UniqueName, Changes: []Change{}})
+		}
+	}
+
+	for _, e := range to {
+		old, ok := fromJobs[key(e)]
+		if !ok {
+			differences = append(differences, Difference{Kind: e.Kind, Namespace: e.Namespace, Name: e.Name, To: e.UniqueName, Changes: []Change{}})
+			continue
+		}
+
+		if old.UniqueName == e.UniqueName && old.Hash == e.Hash {
+			continue
+		}
+
+		differences = append(differences, Difference{
+			Kind:      e.Kind,
+			Namespace: e.Namespace,
+			Name:      e.Name,
+			From:      old.UniqueName,
+			To:        e.UniqueName,
+			Changes:   compareExplanations(old, e),
+		})
+	}
+
+	return differences
+}
+
+func compareExplanations(from, to Explanation) []Change {
+	changes := []Change{}
+	if from.Spec != to.Spec {
+		changes = append(changes, Change{Key: specChangeKey, From: from.Spec, To: to.Spec})
+	}
+
+	fromInputs := inputDigests(from)
+	toInputs := inputDigests(to)
+
+	keys := []string{}
+	for k := range fromInputs {
+		keys = append(keys, k)
+	}
+	for k := range toInputs {
+		if _, ok := fromInputs[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if fromInputs[k] == toInputs[k] {
+			continue
+		}
+
+		change := Change{Key: k, From: fromInputs[k], To: toInputs[k]}
+		if _, ok := from.secrets[k]; ok {
+			change.From = redactedDigest
+		}
+		if _, ok := to.secrets[k]; ok {
+			change.To = redactedDigest
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// inputDigests returns the digests of the inputs of the explanation by their
+// key. Unresolved references are included with an empty digest.
+func inputDigests(e Explanation) map[string]string {
+	digests := map[string]string{}
+	for _, ref := range e.Unresolved {
+		digests[ref.Key] = ""
+	}
+
+	for _, input := range e.Inputs {
+		digests[input.Key] = input.Digest
+	}
+
+	for key, digest := range e.secrets {
+		digests[key] = digest
+	}
+
+	return digests
+}
+
+// FormatExplanations returns the human readable form of the explanations.
+func FormatExplanations(explanations []Explanation) string {
+	buf := bytes.NewBuffer([]byte{})
+	for i, e := range explanations {
+		if i > 0 {
+			fmt.Fprintln(buf)
+		}
+
+		fmt.Fprintf(buf, "%s -> %s\n", displayName(e.Kind, e.Namespace, e.Name), e.UniqueName)
+		fmt.Fprintf(buf, "  hash: %s\n", e.Hash)
+		fmt.Fprintf(buf, "  spec: %s\n", e.Spec)
+		for _, input := range e.Inputs {
+			fmt.Fprintf(buf, "  input %s: %s\n", input.Key, input.Digest)
+		}
+
+		for _, ref := range e.Unresolved {
+			if ref.Optional {
+				fmt.Fprintf(buf, "  unresolved %s (optional)\n", ref.Key)
+			} else {
+				fmt.Fprintf(buf, "  unresolved %s\n", ref.Key)
+			}
+		}
+	}
+
+	return buf.String()
+}
+
+// FormatDifferences returns the human readable form of the differences.
+func FormatDifferences(differences []Difference) string {
+	if len(differences) == 0 {
+		return "No job names changed\n"
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	for i, d := range differences {
+		if i > 0 {
+			fmt.Fprintln(buf)
+		}
+
+		name := displayName(d.Kind, d.Namespace, d.Name)
+		switch {
+		case d.From == "":
+			fmt.Fprintf(buf, "%s: added as %s\n", name, d.To)
+		case d.To == "":
+			fmt.Fprintf(buf, "%s: removed, was %s\n", name, d.From)
+		default:
+			fmt.Fprintf(buf, "%s: %s -> %s\n", name, d.From, d.To)
+		}
+
+		for _, c := range d.Changes {
+			switch {
+			case c.From == "":
+				fmt.Fprintf(buf, "  added %s: %s\n", c.Key, c.To)
+			case c.To == "":
+				fmt.Fprintf(buf, "  removed %s: %s\n", c.Key, c.From)
+			default:
+				fmt.Fprintf(buf, "  changed %s: %s -> %s\n", c.Key, c.From, c.To)
+			}
+		}
+	}
+
+	return buf.String()
+}
+
+// displayName returns the name of a renamed resource as `<namespace>/<name>`,
+// prefixed with its kind when it's not a Job.
+func displayName(kind, ns, name string) string {
+	if kind == "" {
+		return fmt.Sprintf("%s/%s", ns, name)
+	}
+
+	return fmt.Sprintf("%s/%s/%s", kind, ns, name)
+}
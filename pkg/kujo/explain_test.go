@@ -0,0 +1,182 @@
+package kujo
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestExplain(t *testing.T) {
+	explanations := explainFile(t, "testdata/explain-old.yaml")
+
+	expected := []Explanation{
+		{
+			Namespace:  "default",
+			Name:       "migrate",
+			UniqueName: "migrate-kk2588bm5f",
+			Hash:       "332588ba5fc2fe5b15b19bbc431775cbce9962254b47a0349fc74f6416326038",
+			Spec:       "88248dba02b05a79cd60dfca4b8a08a82caa01f670d1ad93beaa16ab615ee0a3",
+			Inputs: []HashInput{
+				{Key: "ConfigMap/default/config/a", Digest: "1fc44241405e8a1e801e97d997b5932b3705398ce76fa43ba1a1080df875aa5a"},
+			},
+			Unresolved: []Reference{{Key: "Secret/default/missing/s"}},
+		},
+	}
+
+	if !cmp.Equal(expected, explanations, cmp.AllowUnexported(Explanation{})) {
+		t.Errorf("Expected explanations to match, got diff %s", cmp.Diff(expected, explanations, cmp.AllowUnexported(Explanation{})))
+	}
+}
+
+func TestCompare(t *testing.T) {
+	from := explainFile(t, "testdata/explain-old.yaml")
+	to := explainFile(t, "testdata/explain-new.yaml")
+
+	tcs := map[string]struct {
+		from   []Explanation
+		to     []Explanation
+		result []Difference
+	}{
+		"without changes": {
+			from:   from,
+			to:     from,
+			result: []Difference{},
+		},
+		"with a changed input": {
+			from: from,
+			to:   to,
+			result: []Difference{
+				{
+					Namespace: "default",
+					Name:      "migrate",
+					From:      "migrate-kk2588bm5f",
+					To:        "migrate-48bbkmgmf5",
+					Changes: []Change{
+						{
+							Key:  "ConfigMap/default/config/a",
+							From: "1fc44241405e8a1e801e97d997b5932b3705398ce76fa43ba1a1080df875aa5a",
+							To:   "4e1b40559376370cbbc8057dbde08fd6ee53c8c74c24b89caed763534c2b0581",
+						},
+					},
+				},
+			},
+		},
+		"with a changed spec": {
+			from: from,
+			to: []Explanation{
+				{Namespace: "default", Name: "migrate", UniqueName: "migrate-abc", Hash: "abc", Spec: "def", Inputs: from[0].Inputs},
+			},
+			result: []Difference{
+				{
+					Namespace: "default",
+					Name:      "migrate",
+					From:      "migrate-kk2588bm5f",
+					To:        "migrate-abc",
+					Changes: []Change{
+						{Key: "spec", From: from[0].Spec, To: "def"},
+					},
+				},
+			},
+		},
+		"with an added job": {
+			from: []Explanation{},
+			to:   from,
+			result: []Difference{
+				{Namespace: "default", Name: "migrate", To: "migrate-kk2588bm5f", Changes: []Change{}},
+			},
+		},
+		"with a removed job": {
+			from: from,
+			to:   []Explanation{},
+			result: []Difference{
+				{Namespace: "default", Name: "migrate", From: "migrate-kk2588bm5f", Changes: []Change{}},
+			},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			result := Compare(tc.from, tc.to)
+			if !cmp.Equal(tc.result, result) {
+				t.Errorf("Expected differences to match, got diff %s", cmp.Diff(tc.result, result))
+			}
+		})
+	}
+}
+
+func TestExplainSecrets(t *testing.T) {
+	input := func(password string) string {
+		return `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+stringData:
+  password: ` + password + `
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    kujo.sphc.io: "true"
+spec:
+  template:
+    spec:
+      containers:
+      - name: m
+        image: perl
+        env:
+        - name: PASSWORD
+          valueFrom:
+            secretKeyRef: {name: credentials, key: password}
+      restartPolicy: Never
+`
+	}
+
+	from, err := Explain(strings.NewReader(input("hunter2")), Options{})
+	if err != nil {
+		t.Fatalf("Did not expect error, got '%s'", err)
+	}
+
+	to, err := Explain(strings.NewReader(input("hunter3")), Options{})
+	if err != nil {
+		t.Fatalf("Did not expect error, got '%s'", err)
+	}
+
+	inputs := []HashInput{{Key: "Secret/default/credentials/password", Digest: redactedDigest}}
+	if !cmp.Equal(inputs, from[0].Inputs) {
+		t.Errorf("Expected the digest of the secret to be redacted, got diff %s", cmp.Diff(inputs, from[0].Inputs))
+	}
+
+	differences := Compare(from, to)
+	if len(differences) != 1 {
+		t.Fatalf("Expected 1 difference, got %d", len(differences))
+	}
+
+	changes := []Change{{Key: "Secret/default/credentials/password", From: redactedDigest, To: redactedDigest}}
+	if !cmp.Equal(changes, differences[0].Changes) {
+		t.Errorf("Expected the change of the secret to be redacted, got diff %s", cmp.Diff(changes, differences[0].Changes))
+	}
+
+	if digest := from[0].secrets["Secret/default/credentials/password"]; strings.Contains(FormatExplanations(from), digest) {
+		t.Errorf("Expected the digest of the secret not to be formatted, got '%s'", FormatExplanations(from))
+	}
+}
+
+func explainFile(t *testing.T, path string) []Explanation {
+	input, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Expected no error opening the input file, got '%s'", err)
+	}
+	defer input.Close()
+
+	explanations, err := Explain(input, Options{})
+	if err != nil {
+		t.Fatalf("Did not expect error, got '%s'", err)
+	}
+
+	return explanations
+}
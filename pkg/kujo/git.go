@@ -0,0 +1,598 @@
+package kujo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hashGitPathAnnKey is the annotation which lists the paths of which the git
+// object ID is folded into the hash of a job.
+const hashGitPathAnnKey = annKey + "/hash-git-path"
+
+// git object types as they are stored in packfiles.
+const (
+	gitObjCommit   = 1
+	gitObjTree     = 2
+	gitObjBlob     = 3
+	gitObjTag      = 4
+	gitObjOfsDelta = 6
+	gitObjRefDelta = 7
+)
+
+var gitObjTypes = map[int]string{
+	gitObjCommit: "commit",
+	gitObjTree:   "tree",
+	gitObjBlob:   "blob",
+	gitObjTag:    "tag",
+}
+
+// gitRepository reads objects from a git repository on disk. It supports loose
+// objects and packfiles with version 2 indexes.
+type gitRepository struct {
+	// root is the working tree of the repository.
+	root string
+
+	// gitDir is the directory holding HEAD, commonDir the directory holding
+	// the refs and objects. They differ for linked worktrees.
+	gitDir    string
+	commonDir string
+
+	objectDirs []string
+	packs      []*gitPack
+}
+
+// gitPack is a packfile together with its index.
+type gitPack struct {
+	path    string
+	ids     [][]byte
+	offsets []int64
+}
+
+// gitTreeInputs returns the git object IDs of the given paths, relative to the
+// base directory, in the HEAD commit of the repository the base directory is
+// part of.
+func gitTreeInputs(paths []string, baseDir string) ([]HashInput, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	if baseDir == "" {
+		baseDir = "."
+	}
+
+	base, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := openGitRepository(base)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make([]HashInput, 0, len(paths))
+	for _, p := range paths {
+		rel, err := filepath.Rel(repo.root, filepath.Join(base, filepath.FromSlash(p)))
+		if err != nil {
+			return nil, err
+		}
+
+		rel = filepath.ToSlash(rel)
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, fmt.Errorf("the path '%s' is outside of the git repository '%s'", p, repo.root)
+		}
+
+		id, err := repo.pathID(rel)
+		if err != nil {
+			return nil, err
+		}
+
+		inputs = append(inputs, HashInput{Key: "GitTree/" + rel, Digest: id})
+	}
+
+	return inputs, nil
+}
+
+// openGitRepository finds the repository which holds the directory.
+func openGitRepository(dir string) (*gitRepository, error) {
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		info, err := os.Stat(gitDir)
+		if err == nil {
+			if !info.IsDir() {
+				// linked worktrees and submodules point to their git directory
+				if gitDir, err = readGitDirFile(gitDir); err != nil {
+					return nil, err
+				}
+			}
+
+			return newGitRepository(dir, gitDir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("could not find a git repository")
+		}
+		dir = parent
+	}
+}
+
+func readGitDirFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir: ") {
+		return "", fmt.Errorf("the file '%s' does not point to a git directory", path)
+	}
+
+	gitDir := strings.TrimPrefix(line, "gitdir: ")
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+
+	return gitDir, nil
+}
+
+func newGitRepository(root, gitDir string) (*gitRepository, error) {
+	repo := &gitRepository{root: root, gitDir: gitDir, commonDir: gitDir}
+	if data, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		repo.commonDir = commonDir
+	}
+
+	objectDir := filepath.Join(repo.commonDir, "objects")
+	repo.objectDirs = []string{objectDir}
+	if data, err := ioutil.ReadFile(filepath.Join(objectDir, "info", "alternates")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(objectDir, line)
+			}
+			repo.objectDirs = append(repo.objectDirs, line)
+		}
+	}
+
+	for _, dir := range repo.objectDirs {
+		indexes, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(indexes)
+
+		for _, index := range indexes {
+			pack, err := readGitPackIndex(index)
+			if err != nil {
+				return nil, err
+			}
+			repo.packs = append(repo.packs, pack)
+		}
+	}
+
+	return repo, nil
+}
+
+// pathID returns the object ID of the tree or blob at the slash separated path
+// in the HEAD commit.
+func (r *gitRepository) pathID(path string) (string, error) {
+	head, err := r.head()
+	if err != nil {
+		return "", err
+	}
+
+	typ, data, err := r.object(head)
+	if err != nil {
+		return "", err
+	}
+
+	// annotated tags can't be checked out, but HEAD could point to one
+	for typ == "tag" {
+		target, err := objectHeader(data, "object")
+		if err != nil {
+			return "", err
+		}
+
+		if typ, data, err = r.object(target); err != nil {
+			return "", err
+		}
+	}
+
+	if typ != "commit" {
+		return "", fmt.Errorf("HEAD points to a %s instead of a commit", typ)
+	}
+
+	id, err := objectHeader(data, "tree")
+	if err != nil {
+		return "", err
+	}
+
+	if path == "." {
+		return id, nil
+	}
+
+	for _, name := range strings.Split(path, "/") {
+		typ, data, err := r.object(id)
+		if err != nil {
+			return "", err
+		}
+
+		if typ != "tree" {
+			return "", fmt.Errorf("the path '%s' does not exist in the HEAD commit", path)
+		}
+
+		if id, err = treeEntry(data, name); err != nil {
+			return "", fmt.Errorf("the path '%s' does not exist in the HEAD commit", path)
+		}
+	}
+
+	return id, nil
+}
+
+// head returns the ID of the commit HEAD points to.
+func (r *gitRepository) head() (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(r.gitDir, "HEAD"))
+	if err != nil {
+		return "", err
+	}
+
+	ref := strings.TrimSpace(string(data))
+	for i := 0; strings.HasPrefix(ref, "ref: "); i++ {
+		if i > 10 {
+			return "", fmt.Errorf("too many levels of symbolic references")
+		}
+
+		if ref, err = r.ref(strings.TrimPrefix(ref, "ref: ")); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := hex.DecodeString(ref); err != nil || len(ref) != 40 {
+		return "", fmt.Errorf("HEAD does not point to a commit")
+	}
+
+	return ref, nil
+}
+
+// ref returns the value of the reference, either from its file or from the
+// packed references.
+func (r *gitRepository) ref(name string) (string, error) {
+	for _, dir := range []string{r.gitDir, r.commonDir} {
+		if data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+	if err != nil {
+		return "", fmt.Errorf("could not resolve the reference '%s'", name)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == name {
+			return fields[0], nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", fmt.Errorf("could not resolve the reference '%s'", name)
+}
+
+// object returns the type and the content of the object with the given ID.
+func (r *gitRepository) object(id string) (string, []byte, error) {
+	for _, dir := range r.objectDirs {
+		typ, data, err := readLooseObject(filepath.Join(dir, id[:2], id[2:]))
+		if err == nil {
+			return typ, data, nil
+		}
+
+		if !os.IsNotExist(err) {
+			return "", nil, err
+		}
+	}
+
+	raw, err := hex.DecodeString(id)
+	if err != nil {
+		return "", nil, fmt.Errorf("the object ID '%s' is invalid", id)
+	}
+
+	for _, pack := range r.packs {
+		if offset, ok := pack.find(raw); ok {
+			return r.packObject(pack, offset)
+		}
+	}
+
+	return "", nil, fmt.Errorf("could not find the object '%s'", id)
+}
+
+func readLooseObject(path string) (string, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	i := bytes.IndexByte(data, 0)
+	if i == -1 {
+		return "", nil, fmt.Errorf("the object '%s' is invalid", path)
+	}
+
+	header := strings.SplitN(string(data[:i]), " ", 2)
+	return header[0], data[i+1:], nil
+}
+
+// readGitPackIndex reads the object IDs and their offsets from a version 2
+// pack index.
+func readGitPackIndex(path string) (*gitPack, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 8+256*4 || !bytes.Equal(data[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(data[4:8]) != 2 {
+		return nil, fmt.Errorf("the pack index '%s' is not a version 2 index", path)
+	}
+
+	count := int(binary.BigEndian.Uint32(data[8+255*4:]))
+	idStart := 8 + 256*4
+	offsetStart := idStart + count*20 + count*4
+	largeStart := offsetStart + count*4
+	if len(data) < largeStart {
+		return nil, fmt.Errorf("the pack index '%s' is truncated", path)
+	}
+
+	pack := &gitPack{
+		path:    strings.TrimSuffix(path, ".idx") + ".pack",
+		ids:     make([][]byte, count),
+		offsets: make([]int64, count),
+	}
+
+	for i := 0; i < count; i++ {
+		pack.ids[i] = data[idStart+i*20 : idStart+(i+1)*20]
+
+		offset := binary.BigEndian.Uint32(data[offsetStart+i*4:])
+		if offset&0x80000000 == 0 {
+			pack.offsets[i] = int64(offset)
+			continue
+		}
+
+		large := largeStart + int(offset&0x7fffffff)*8
+		if len(data) < large+8 {
+			return nil, fmt.Errorf("the pack index '%s' is truncated", path)
+		}
+		pack.offsets[i] = int64(binary.BigEndian.Uint64(data[large:]))
+	}
+
+	return pack, nil
+}
+
+// find returns the offset of the object in the packfile.
+func (p *gitPack) find(id []byte) (int64, bool) {
+	i := sort.Search(len(p.ids), func(i int) bool {
+		return bytes.Compare(p.ids[i], id) >= 0
+	})
+
+	if i < len(p.ids) && bytes.Equal(p.ids[i], id) {
+		return p.offsets[i], true
+	}
+
+	return 0, false
+}
+
+// packObject reads the object at the offset of the packfile and resolves it
+// when it's stored as a delta.
+func (r *gitRepository) packObject(pack *gitPack, offset int64) (string, []byte, error) {
+	f, err := os.Open(pack.path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	rdr := bufio.NewReader(io.NewSectionReader(f, offset, 1<<62))
+
+	c, err := rdr.ReadByte()
+	if err != nil {
+		return "", nil, err
+	}
+
+	typ := int(c>>4) & 7
+	for c&0x80 != 0 {
+		// the size is not needed, zlib knows where the data ends
+		if c, err = rdr.ReadByte(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var base func() (string, []byte, error)
+	switch typ {
+	case gitObjOfsDelta:
+		c, err := rdr.ReadByte()
+		if err != nil {
+			return "", nil, err
+		}
+
+		distance := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = rdr.ReadByte(); err != nil {
+				return "", nil, err
+			}
+			distance = ((distance + 1) << 7) | int64(c&0x7f)
+		}
+
+		base = func() (string, []byte, error) {
+			return r.packObject(pack, offset-distance)
+		}
+	case gitObjRefDelta:
+		id := make([]byte, 20)
+		if _, err := io.ReadFull(rdr, id); err != nil {
+			return "", nil, err
+		}
+
+		base = func() (string, []byte, error) {
+			return r.object(hex.EncodeToString(id))
+		}
+	default:
+		if _, ok := gitObjTypes[typ]; !ok {
+			return "", nil, fmt.Errorf("unknown object type %d in '%s'", typ, pack.path)
+		}
+	}
+
+	zr, err := zlib.NewReader(rdr)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if base == nil {
+		return gitObjTypes[typ], data, nil
+	}
+
+	baseType, baseData, err := base()
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err = applyDelta(baseData, data)
+	return baseType, data, err
+}
+
+// applyDelta reconstructs an object from its base and a delta.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	rdr := bytes.NewReader(delta)
+
+	// the sizes of the base and the result
+	if _, err := binary.ReadUvarint(rdr); err != nil {
+		return nil, err
+	}
+
+	size, err := binary.ReadUvarint(rdr)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, size)
+	for rdr.Len() > 0 {
+		cmd, _ := rdr.ReadByte()
+		switch {
+		case cmd&0x80 != 0:
+			var offset, length uint32
+			for i := uint(0); i < 4; i++ {
+				if cmd&(1<<i) != 0 {
+					b, err := rdr.ReadByte()
+					if err != nil {
+						return nil, err
+					}
+					offset |= uint32(b) << (8 * i)
+				}
+			}
+
+			for i := uint(0); i < 3; i++ {
+				if cmd&(1<<(4+i)) != 0 {
+					b, err := rdr.ReadByte()
+					if err != nil {
+						return nil, err
+					}
+					length |= uint32(b) << (8 * i)
+				}
+			}
+
+			if length == 0 {
+				length = 0x10000
+			}
+
+			if int(offset)+int(length) > len(base) {
+				return nil, fmt.Errorf("the delta copies data outside of its base")
+			}
+			result = append(result, base[offset:offset+length]...)
+		case cmd != 0:
+			insert := make([]byte, cmd)
+			if _, err := io.ReadFull(rdr, insert); err != nil {
+				return nil, err
+			}
+			result = append(result, insert...)
+		default:
+			return nil, fmt.Errorf("the delta holds an invalid instruction")
+		}
+	}
+
+	if uint64(len(result)) != size {
+		return nil, fmt.Errorf("the delta results in %d instead of %d bytes", len(result), size)
+	}
+
+	return result, nil
+}
+
+// objectHeader returns the value of a header of a commit or tag object.
+func objectHeader(data []byte, name string) (string, error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break
+		}
+
+		if strings.HasPrefix(line, name+" ") {
+			return strings.TrimPrefix(line, name+" "), nil
+		}
+	}
+
+	return "", fmt.Errorf("the object has no %s", name)
+}
+
+// treeEntry returns the object ID of the entry with the given name in a tree.
+func treeEntry(data []byte, name string) (string, error) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, 0)
+		if i == -1 || len(data) < i+21 {
+			return "", fmt.Errorf("the tree is invalid")
+		}
+
+		// entries are written as `<mode> <name>\0<20 byte ID>`
+		entry := string(data[:i])
+		if sp := strings.IndexByte(entry, ' '); sp != -1 && entry[sp+1:] == name {
+			return hex.EncodeToString(data[i+1 : i+21]), nil
+		}
+
+		data = data[i+21:]
+	}
+
+	return "", fmt.Errorf("the tree has no entry '%s'", name)
+}
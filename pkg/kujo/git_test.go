@@ -0,0 +1,156 @@
+package kujo
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitTreeInputs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "kujo")
+	if err != nil {
+		t.Fatalf("Expected no error creating a directory, got '%s'", err)
+	}
+	defer os.RemoveAll(dir)
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=kujo", "-c", "user.email=kujo@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("Expected no error running git %v, got '%s': %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	write := func(name, content string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Expected no error creating a directory, got '%s'", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Expected no error writing a file, got '%s'", err)
+		}
+	}
+
+	migration := strings.Repeat("INSERT INTO users (id) VALUES (1);\n", 100)
+	git("init", "-q")
+	write("db/migrations/001_users.sql", migration)
+	write("db/migrations/002_accounts.sql", "CREATE TABLE accounts (id INT);\n")
+	write("deploy/job.yaml", "kind: Job\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "Add migrations")
+
+	write("db/migrations/001_users.sql", migration+"INSERT INTO users (id) VALUES (2);\n")
+	git("commit", "-q", "-a", "-m", "Update migrations")
+
+	tcs := map[string]struct {
+		paths   []string
+		baseDir string
+		revs    []string
+		err     bool
+	}{
+		"with a directory": {
+			paths:   []string{"db/migrations"},
+			baseDir: dir,
+			revs:    []string{"HEAD:db/migrations"},
+		},
+		"with a file": {
+			paths:   []string{"db/migrations/001_users.sql"},
+			baseDir: dir,
+			revs:    []string{"HEAD:db/migrations/001_users.sql"},
+		},
+		"with a relative path": {
+			paths:   []string{"../db", "."},
+			baseDir: filepath.Join(dir, "deploy"),
+			revs:    []string{"HEAD:db", "HEAD:deploy"},
+		},
+		"with the root of the repository": {
+			paths:   []string{"."},
+			baseDir: dir,
+			revs:    []string{"HEAD^{tree}"},
+		},
+		"with a path which isn't committed": {
+			paths:   []string{"db/seeds"},
+			baseDir: dir,
+			err:     true,
+		},
+		"with a path outside of the repository": {
+			paths:   []string{"../other"},
+			baseDir: dir,
+			err:     true,
+		},
+	}
+
+	for _, packed := range []bool{false, true} {
+		if packed {
+			git("gc", "-q", "--aggressive")
+		}
+
+		for name, tc := range tcs {
+			if packed {
+				name += " in a packfile"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				inputs, err := gitTreeInputs(tc.paths, tc.baseDir)
+				if tc.err != (err != nil) {
+					t.Errorf("Expected error to be %t, got '%v'", tc.err, err)
+				}
+
+				// we've got an error, don't run further tests
+				if err != nil {
+					return
+				}
+
+				if len(inputs) != len(tc.revs) {
+					t.Fatalf("Expected %d inputs, got %d", len(tc.revs), len(inputs))
+				}
+
+				for i, rev := range tc.revs {
+					if id := git("rev-parse", rev); inputs[i].Digest != id {
+						t.Errorf("Expected object ID '%s' for '%s', got '%s'", id, rev, inputs[i].Digest)
+					}
+				}
+			})
+		}
+	}
+
+	// the packfile stores older versions of objects as deltas
+	repo, err := openGitRepository(dir)
+	if err != nil {
+		t.Fatalf("Expected no error opening the repository, got '%s'", err)
+	}
+
+	for _, line := range strings.Split(git("rev-list", "--objects", "--all"), "\n") {
+		id := strings.Fields(line)[0]
+		typ, data, err := repo.object(id)
+		if err != nil {
+			t.Fatalf("Expected no error reading object '%s', got '%s'", id, err)
+		}
+
+		if expected := git("cat-file", "-t", id); typ != expected {
+			t.Errorf("Expected object '%s' to be a %s, got %s", id, expected, typ)
+		}
+
+		if expected := git("cat-file", "-s", id); fmt.Sprint(len(data)) != expected {
+			t.Errorf("Expected object '%s' to hold %s bytes, got %d", id, expected, len(data))
+		}
+
+		sum := sha1.Sum(append([]byte(fmt.Sprintf("%s %d\x00", typ, len(data))), data...))
+		if hex.EncodeToString(sum[:]) != id {
+			t.Errorf("Expected the content of object '%s' to match its ID", id)
+		}
+	}
+}
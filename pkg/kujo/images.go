@@ -0,0 +1,110 @@
+package kujo
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+	cv1 "k8s.io/api/core/v1"
+)
+
+// ReadImageLock reads an image lock file, which maps image references to their
+// digest. The file is a YAML or JSON object, the digests are written either as
+// `sha256:...` or as a full reference like `myapp@sha256:...`.
+func ReadImageLock(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries map[string]string
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("could not parse the image lock file '%s': %s", path, err)
+	}
+
+	lock := map[string]string{}
+	for image, digest := range entries {
+		if i := strings.LastIndex(digest, "@"); i != -1 {
+			digest = digest[i+1:]
+		}
+
+		if !isDigest(digest) {
+			return nil, fmt.Errorf("the digest '%s' of image '%s' is invalid", digest, image)
+		}
+		lock[image] = digest
+	}
+
+	return lock, nil
+}
+
+// isDigest reports if the value is a digest like `sha256:...`.
+func isDigest(value string) bool {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return false
+	}
+
+	_, err := hex.DecodeString(parts[1])
+	return err == nil && parts[1] != ""
+}
+
+// lockedDigest returns the digest of the image from the lock. Images without a
+// tag are looked up with the implicit `latest` tag as well.
+func lockedDigest(lock map[string]string, image string) (string, bool) {
+	if digest, ok := lock[image]; ok {
+		return digest, true
+	}
+
+	if !strings.Contains(image, "@") && !strings.Contains(image[strings.LastIndex(image, "/")+1:], ":") {
+		digest, ok := lock[image+":latest"]
+		return digest, ok
+	}
+
+	return "", false
+}
+
+// imageInputs returns the digests of the images of the containers which are
+// part of the image lock.
+func imageInputs(containers []cv1.Container, lock map[string]string) []HashInput {
+	var inputs []HashInput
+	seen := map[string]bool{}
+	for _, container := range containers {
+		if seen[container.Image] {
+			continue
+		}
+		seen[container.Image] = true
+
+		if digest, ok := lockedDigest(lock, container.Image); ok {
+			inputs = append(inputs, HashInput{Key: "Image/" + container.Image, Digest: digest})
+		}
+	}
+
+	return inputs
+}
+
+// pinImages replaces the images of the containers which are part of the image
+// lock with a reference to their digest.
+func pinImages(containers []map[string]interface{}, lock map[string]string) {
+	for _, container := range containers {
+		image, _ := container["image"].(string)
+		if digest, ok := lockedDigest(lock, image); ok {
+			container["image"] = pinnedImage(image, digest)
+		}
+	}
+}
+
+// pinnedImage returns the reference to the image by its digest, without its
+// tag.
+func pinnedImage(image, digest string) string {
+	if i := strings.Index(image, "@"); i != -1 {
+		image = image[:i]
+	}
+
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+
+	return image + "@" + digest
+}
@@ -0,0 +1,117 @@
+package kujo
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadImageLock(t *testing.T) {
+	lock, err := ReadImageLock("testdata/image-lock.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error reading the image lock, got '%s'", err)
+	}
+
+	expected := map[string]string{
+		"perl":                                  "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		"registry.example.com:5000/migrate:1.2": "sha256:fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210",
+	}
+	if !cmp.Equal(expected, lock) {
+		t.Errorf("Expected image lock to match, got diff %s", cmp.Diff(expected, lock))
+	}
+}
+
+func TestPinnedImage(t *testing.T) {
+	lock := map[string]string{
+		"perl:latest":                           "sha256:0123",
+		"registry.example.com:5000/migrate:1.2": "sha256:4567",
+		"registry.example.com:5000/migrate":     "sha256:89ab",
+	}
+
+	tcs := map[string]struct {
+		image  string
+		pinned string
+	}{
+		"with an implicit latest tag": {
+			image:  "perl",
+			pinned: "perl@sha256:0123",
+		},
+		"with a tag and a registry port": {
+			image:  "registry.example.com:5000/migrate:1.2",
+			pinned: "registry.example.com:5000/migrate@sha256:4567",
+		},
+		"with a registry port without a tag": {
+			image:  "registry.example.com:5000/migrate",
+			pinned: "registry.example.com:5000/migrate@sha256:89ab",
+		},
+		"with an image which isn't locked": {
+			image: "ruby",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			digest, ok := lockedDigest(lock, tc.image)
+			if ok != (tc.pinned != "") {
+				t.Fatalf("Expected the image to be locked to be %t", tc.pinned != "")
+			}
+
+			if !ok {
+				return
+			}
+
+			if pinned := pinnedImage(tc.image, digest); pinned != tc.pinned {
+				t.Errorf("Expected pinned image '%s', got '%s'", tc.pinned, pinned)
+			}
+		})
+	}
+}
+
+func TestConvertImageLock(t *testing.T) {
+	lock, err := ReadImageLock("testdata/image-lock.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error reading the image lock, got '%s'", err)
+	}
+
+	convert := func(opts Options) string {
+		input, err := os.Open("testdata/convert-input.yaml")
+		if err != nil {
+			t.Fatalf("Expected no error opening the input file, got '%s'", err)
+		}
+		defer input.Close()
+
+		output, err := Convert(input, opts)
+		if err != nil {
+			t.Fatalf("Did not expect error, got '%s'", err)
+		}
+
+		return string(output)
+	}
+
+	original := convert(Options{})
+	locked := convert(Options{ImageLock: lock})
+	pinned := convert(Options{ImageLock: lock, PinImages: true})
+
+	if strings.Contains(locked, "name: pi-f7k7cmcf6b") {
+		t.Errorf("Expected the job name to change with the image lock")
+	}
+
+	if !strings.Contains(locked, "name: pi-58c79hfmkf") || !strings.Contains(pinned, "name: pi-58c79hfmkf") {
+		t.Errorf("Expected the job to have the same name with and without pinning, got\n%s\n%s", locked, pinned)
+	}
+
+	if strings.Contains(locked, "@sha256") || strings.Contains(original, "@sha256") {
+		t.Errorf("Expected images to be pinned only with PinImages")
+	}
+
+	if !strings.Contains(pinned, "image: perl@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef") {
+		t.Errorf("Expected the image to be pinned, got\n%s", pinned)
+	}
+
+	// jobs which aren't renamed are left alone
+	if strings.Count(pinned, "@sha256") != 1 {
+		t.Errorf("Expected only the renamed job to be pinned, got\n%s", pinned)
+	}
+}
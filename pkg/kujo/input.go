@@ -0,0 +1,123 @@
+package kujo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// manifestExtensions are the file extensions which are picked up when walking
+// a directory.
+var manifestExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+}
+
+// FilesFromPaths expands a list of files, directories and glob patterns into a
+// list of files. Directories are walked recursively and only files with a YAML
+// or JSON extension are included. The order of the given paths is kept, files
+// within a directory or matching a glob pattern are sorted lexically and
+// duplicate files are only returned once.
+func FilesFromPaths(paths []string) ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+	add := func(file string) {
+		file = filepath.Clean(file)
+		if !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+
+	for _, path := range paths {
+		matches := []string{path}
+		if isGlob(path) {
+			var err error
+			matches, err = filepath.Glob(path)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match the pattern '%s'", path)
+			}
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+
+			err = filepath.Walk(match, func(file string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if !info.IsDir() && manifestExtensions[strings.ToLower(filepath.Ext(file))] {
+					add(file)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// ReaderFromPaths expands the given paths with FilesFromPaths and concatenates
+// the content of all files into a single YAML stream, so the resources of all
+// files can be processed together.
+func ReaderFromPaths(paths []string) (io.Reader, error) {
+	files, err := FilesFromPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		// Separate the files with a document separator. When the first file
+		// holds JSON it gets a separator as well, this forces the decoder into
+		// YAML mode which also accepts the JSON documents.
+		if buf.Len() > 0 || bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+			if _, err := buf.WriteString("---\n"); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := buf.Write(data); err != nil {
+			return nil, err
+		}
+
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			if err := buf.WriteByte('\n'); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return buf, nil
+}
+
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
@@ -0,0 +1,92 @@
+package kujo
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFilesFromPaths(t *testing.T) {
+	tcs := map[string]struct {
+		paths []string
+		files []string
+		err   bool
+	}{
+		"with a single file": {
+			paths: []string{"testdata/input/jobs/migrate.yaml"},
+			files: []string{"testdata/input/jobs/migrate.yaml"},
+		},
+		"with a directory": {
+			paths: []string{"testdata/input/config"},
+			files: []string{
+				"testdata/input/config/json-config.json",
+				"testdata/input/config/migrate-config.yaml",
+				"testdata/input/config/nested/secret.yml",
+			},
+		},
+		"with a glob pattern": {
+			paths: []string{"testdata/input/*/*.yaml"},
+			files: []string{
+				"testdata/input/config/migrate-config.yaml",
+				"testdata/input/jobs/migrate.yaml",
+			},
+		},
+		"with duplicate paths": {
+			paths: []string{"testdata/input/jobs", "testdata/input/config/migrate-config.yaml", "testdata/input"},
+			files: []string{
+				"testdata/input/jobs/migrate.yaml",
+				"testdata/input/config/migrate-config.yaml",
+				"testdata/input/config/json-config.json",
+				"testdata/input/config/nested/secret.yml",
+			},
+		},
+		"with a missing file": {
+			paths: []string{"testdata/input/missing.yaml"},
+			err:   true,
+		},
+		"with a glob pattern without matches": {
+			paths: []string{"testdata/input/*.txt"},
+			err:   true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			files, err := FilesFromPaths(tc.paths)
+			if tc.err != (err != nil) {
+				t.Errorf("Expected error to be %t, got '%v'", tc.err, err)
+			}
+
+			// we've got an error, don't run further tests
+			if err != nil {
+				return
+			}
+
+			if !cmp.Equal(tc.files, files) {
+				t.Errorf("Expected files to equal\n\n%s\n\ngot\n\n%s", tc.files, files)
+			}
+		})
+	}
+}
+
+func TestReaderFromPaths(t *testing.T) {
+	rdr, err := ReaderFromPaths([]string{"testdata/input/config", "testdata/input/jobs"})
+	if err != nil {
+		t.Fatalf("Expected no error reading the paths, got '%s'", err)
+	}
+
+	rs, err := ResourcesFromReader(rdr)
+	if err != nil {
+		t.Fatalf("Expected no error getting the resources, got '%s'", err)
+	}
+
+	names := []string{}
+	for _, r := range rs {
+		names = append(names, r.GetName())
+	}
+
+	expected := []string{"json-config", "migrate-config", "migrate-secret", "migrate"}
+	if !cmp.Equal(expected, names) {
+		t.Errorf("Expected resources to equal\n\n%s\n\ngot\n\n%s", expected, names)
+	}
+}
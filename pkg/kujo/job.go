@@ -4,11 +4,14 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"log"
+	"sort"
 	"strings"
 
 	v1 "k8s.io/api/batch/v1"
 	cv1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // JobSlice goes over a set of unstructured objects and returns a new slice with
@@ -38,125 +41,465 @@ func JobSlice(uList []unstructured.Unstructured) ([]v1.Job, error) {
 // original namespace and name for the job so it can be mapped back to the
 // original list of resources.
 func HashedJobs(jobs []v1.Job, config map[string]string) (map[string]string, error) {
-	hashedJobs := map[string]string{}
+	uList := make([]unstructured.Unstructured, 0, len(jobs))
 	for _, job := range jobs {
-		ns := job.Namespace
-		if ns == "" {
-			ns = "default"
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&job)
+		if err != nil {
+			return nil, err
+		}
+
+		uList = append(uList, unstructured.Unstructured{Object: obj})
+	}
+
+	hashes, err := hashJobs(uList, config, Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	hashedJobs := map[string]string{}
+	for key, hash := range hashes {
+		hashedJobs[key] = hash.Short
+	}
+
+	return hashedJobs, nil
+}
+
+// jobHash is the hash which uniquely identifies a job and its configuration.
+type jobHash struct {
+	// Full is the hex encoded SHA256 hash.
+	Full string
+
+	// Short is the encoded hash which is used as suffix for the job name.
+	Short string
+
+	// Spec is the hash of the job specification.
+	Spec string
+
+	// Inputs is the configuration which contributed to the hash.
+	Inputs []HashInput
+
+	// Unresolved are the references to configuration which is not part of
+	// the input.
+	Unresolved []Reference
+}
+
+// Reference is a reference from a job to a ConfigMap or Secret, or a single
+// value of it.
+type Reference struct {
+	Key string `json:"key"`
+
+	// Optional is set when the reference is marked as optional, the job can
+	// run without the referenced configuration.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// HashInput is a ConfigMap or Secret, or a single value of it, which
+// contributed to the hash of a job.
+type HashInput struct {
+	Key    string `json:"key"`
+	Digest string `json:"digest"`
+}
+
+// hashJobs works like HashedJobs, but uses the unstructured jobs as they were
+// read from the input. This makes sure fields which aren't known to the
+// vendored Kubernetes API are taken into account. References to ConfigMaps and
+// Secrets are still discovered through the known fields of the pod specs.
+// Resources of other workload kinds are hashed the same way, through the pod
+// specs and containers their kind declares.
+func hashJobs(jobs []unstructured.Unstructured, config map[string]string, opts Options) (map[string]jobHash, error) {
+	hashedJobs := map[string]jobHash{}
+	for _, un := range jobs {
+		kind, ok := workloadKind(un, opts)
+		if !ok {
+			kind = jobKind
+		}
+
+		pods, err := workloadPods(un, kind)
+		if err != nil {
+			return nil, err
+		}
+
+		specData, err := jobSpecData(un, opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not hash the spec of job '%s': %s", un.GetName(), err)
+		}
+
+		files, err := pathInputs(annotationList(un.GetAnnotations(), hashPathsAnnKey), opts.BaseDir)
+		if err != nil {
+			return nil, fmt.Errorf("could not hash the paths of job '%s': %s", un.GetName(), err)
+		}
+
+		trees, err := gitTreeInputs(annotationList(un.GetAnnotations(), hashGitPathAnnKey), opts.BaseDir)
+		if err != nil {
+			return nil, fmt.Errorf("could not resolve the git paths of job '%s': %s", un.GetName(), err)
+		}
+
+		ns := opts.namespace(un.GetNamespace())
+		var refs []Reference
+		var containers []cv1.Container
+		for _, pod := range pods {
+			refs = append(refs, jobVolumeRefs(ns, pod)...)
+			refs = append(refs, jobContainerRefs(ns, pod)...)
+			containers = append(containers, pod.containers()...)
+		}
+
+		dependencies, err := dependencyRefs(un, ns, config)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the dependencies of job '%s': %s", un.GetName(), err)
+		}
+		refs = append(refs, dependencies...)
+
+		// the canonical spec sorts the lists which hold the references, so
+		// the references are sorted as well to hash them in the same order
+		if opts.Canonicalize {
+			sortReferences(refs)
 		}
 
-		hj, err := hashedJobName(job, config)
+		extra := append(files, trees...)
+		extra = append(extra, imageInputs(containers, opts.ImageLock)...)
+		hj, err := hashedJobName(specData, refs, config, extra)
 		if err != nil {
 			return nil, err
 		}
 
-		key := fmt.Sprintf("%s/%s", ns, job.Name)
-		hashedJobs[key] = hj
+		hashedJobs[workloadKey(un, kind, ns)] = hj
 	}
 
 	return hashedJobs, nil
 }
 
-func hashedJobName(job v1.Job, config map[string]string) (string, error) {
-	specData, err := json.Marshal(job.Spec)
+// sortReferences sorts the references by their key, required references
+// before optional ones.
+func sortReferences(refs []Reference) {
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].Key != refs[j].Key {
+			return refs[i].Key < refs[j].Key
+		}
+
+		return !refs[i].Optional && refs[j].Optional
+	})
+}
+
+// workloadPods decodes the pod specs of the resource. The containers of the
+// kind which are not part of a pod spec are returned as a pod of their own.
+func workloadPods(un unstructured.Unstructured, kind WorkloadKind) ([]podSpec, error) {
+	var pods []podSpec
+	for _, obj := range kind.podSpecs(un.Object) {
+		var pod podSpec
+		if err := fromUnstructured(obj, &pod); err != nil {
+			return nil, err
+		}
+		pods = append(pods, pod)
+	}
+
+	if containers := kind.containers(un.Object); len(containers) > 0 {
+		var pod podSpec
+		for _, obj := range containers {
+			var container cv1.Container
+			if err := fromUnstructured(obj, &container); err != nil {
+				return nil, err
+			}
+			pod.Containers = append(pod.Containers, container)
+		}
+		pods = append(pods, pod)
+	}
+
+	return pods, nil
+}
+
+// workloadKey returns the key of a job in the map of hashed jobs, in the
+// `<namespace>/<name>` format. Resources of other workload kinds hold their
+// kind in the key as well, like `Pod/<namespace>/<name>`.
+func workloadKey(un unstructured.Unstructured, kind WorkloadKind, ns string) string {
+	if kind.Kind == jobKind.Kind {
+		return fmt.Sprintf("%s/%s", ns, un.GetName())
+	}
+
+	return resourceKey(un.GroupVersionKind().Group, kind.Kind, ns, un.GetName())
+}
+
+// podSpec is the specification of a pod template. Next to the fields of the
+// vendored PodSpec, it holds the fields which were added to Kubernetes later
+// on.
+type podSpec struct {
+	cv1.PodSpec
+	EphemeralContainers []cv1.Container `json:"ephemeralContainers,omitempty"`
+}
+
+// containers returns all the containers of the pod: init containers, regular
+// containers and ephemeral containers.
+func (p podSpec) containers() []cv1.Container {
+	containers := append([]cv1.Container{}, p.InitContainers...)
+	containers = append(containers, p.Containers...)
+	return append(containers, p.EphemeralContainers...)
+}
+
+func fromUnstructured(obj interface{}, into interface{}) error {
+	data, err := json.Marshal(obj)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	hashes := []string{fmt.Sprintf("%x", sha256.Sum256([]byte(specData)))}
-	hashes = append(hashes, jobVolumeHashes(job, config)...)
-	hashes = append(hashes, jobContainerHashes(job, config)...)
+	return json.Unmarshal(data, into)
+}
+
+// jobSpecData returns the data of the job specification which is hashed. The
+// specification is used as it was read, so fields which aren't known to the
+// vendored Kubernetes API are included. Fields which are excluded through the
+// options or the annotation of the job are removed. With Canonicalize set, the
+// specification is brought into its canonical form last.
+func jobSpecData(job unstructured.Unstructured, opts Options) ([]byte, error) {
+	spec, _ := job.Object["spec"].(map[string]interface{})
+	if spec == nil {
+		return json.Marshal(spec)
+	}
+
+	excludes := append([]string{}, opts.HashExclude...)
+	excludes = append(excludes, annotationList(job.GetAnnotations(), hashExcludeAnnKey)...)
+	spec, err := excludeFields(spec, excludes)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Canonicalize {
+		kind, ok := workloadKind(job, opts)
+		if !ok {
+			kind = jobKind
+		}
+		spec = canonicalSpec(kind, spec)
+	}
 
-	return encodeHashSlice(hashes)
+	return json.Marshal(spec)
 }
 
-func jobContainerHashes(job v1.Job, config map[string]string) []string {
-	ns := job.Namespace
-	if ns == "" {
-		ns = "default"
+// hashedJobName calculates the hash of a job from its specification, the
+// digests of the resources it references and the given extra inputs, like the
+// digests of files.
+func hashedJobName(specData []byte, refs []Reference, config map[string]string, extra []HashInput) (jobHash, error) {
+	spec := fmt.Sprintf("%x", sha256.Sum256([]byte(specData)))
+	hashes := []string{spec}
+	inputs := []HashInput{}
+	unresolved := []Reference{}
+	for _, ref := range refs {
+		digest, ok := config[ref.Key]
+		if !ok {
+			unresolved = append(unresolved, ref)
+			continue
+		}
+
+		hashes = append(hashes, digest)
+		inputs = append(inputs, HashInput{Key: ref.Key, Digest: digest})
 	}
-	hashes := []string{}
-	for _, container := range job.Spec.Template.Spec.Containers {
-		hashes = append(hashes, containerEnvHashes(ns, container, config)...)
-		hashes = append(hashes, containerEnvFromHashes(ns, container, config)...)
+
+	for _, input := range extra {
+		hashes = append(hashes, input.Digest)
+		inputs = append(inputs, input)
 	}
-	return hashes
+
+	hash, err := encodeHashSlice(hashes)
+	if err != nil {
+		return jobHash{}, err
+	}
+
+	hash.Spec = spec
+	hash.Inputs = inputs
+	hash.Unresolved = unresolved
+	return hash, nil
 }
 
-func containerEnvHashes(ns string, container cv1.Container, config map[string]string) []string {
-	hashes := []string{}
+// jobContainerRefs returns the references to the configuration used by the
+// environment of all containers of the job, including init and ephemeral
+// containers.
+func jobContainerRefs(ns string, pod podSpec) []Reference {
+	refs := []Reference{}
+	for _, container := range pod.containers() {
+		refs = append(refs, containerEnvRefs(ns, container)...)
+		refs = append(refs, containerEnvFromRefs(ns, container)...)
+	}
+	return refs
+}
+
+// containerEnvRefs returns the references to the ConfigMap and Secret values
+// which are used by the environment variables of the container. Only the key
+// of the referenced value is used, so changes to other values of the same
+// object don't change the hash.
+func containerEnvRefs(ns string, container cv1.Container) []Reference {
+	refs := []Reference{}
 
 	for _, env := range container.Env {
 		if vf := env.ValueFrom; vf != nil {
 			if cmr := vf.ConfigMapKeyRef; cmr != nil {
-				key := fmt.Sprintf("ConfigMap/%s/%s", ns, cmr.LocalObjectReference.Name)
-				if val, ok := config[key]; ok {
-					hashes = append(hashes, val)
-				}
+				refs = append(refs, Reference{
+					Key:      configKey("ConfigMap", ns, cmr.LocalObjectReference.Name, cmr.Key),
+					Optional: isOptional(cmr.Optional),
+				})
 			}
 
 			if sr := vf.SecretKeyRef; sr != nil {
-				key := fmt.Sprintf("Secret/%s/%s", ns, sr.LocalObjectReference.Name)
-				if val, ok := config[key]; ok {
-					hashes = append(hashes, val)
-				}
+				refs = append(refs, Reference{
+					Key:      configKey("Secret", ns, sr.LocalObjectReference.Name, sr.Key),
+					Optional: isOptional(sr.Optional),
+				})
 			}
 		}
 	}
 
-	return hashes
+	return refs
 }
 
-func containerEnvFromHashes(ns string, container cv1.Container, config map[string]string) []string {
-	hashes := []string{}
+func containerEnvFromRefs(ns string, container cv1.Container) []Reference {
+	refs := []Reference{}
 
 	for _, env := range container.EnvFrom {
 		if cmr := env.ConfigMapRef; cmr != nil {
-			key := fmt.Sprintf("ConfigMap/%s/%s", ns, cmr.LocalObjectReference.Name)
-			if val, ok := config[key]; ok {
-				hashes = append(hashes, val)
-			}
+			refs = append(refs, Reference{
+				Key:      configKey("ConfigMap", ns, cmr.LocalObjectReference.Name, ""),
+				Optional: isOptional(cmr.Optional),
+			})
 		}
 
 		if sr := env.SecretRef; sr != nil {
-			key := fmt.Sprintf("Secret/%s/%s", ns, sr.LocalObjectReference.Name)
-			if val, ok := config[key]; ok {
-				hashes = append(hashes, val)
-			}
+			refs = append(refs, Reference{
+				Key:      configKey("Secret", ns, sr.LocalObjectReference.Name, ""),
+				Optional: isOptional(sr.Optional),
+			})
 		}
 	}
 
-	return hashes
+	return refs
 }
 
-func jobVolumeHashes(job v1.Job, config map[string]string) []string {
-	if job.Spec.Template.Spec.Volumes == nil {
-	}
-
-	ns := job.Namespace
-	hashes := []string{}
-	for _, volume := range job.Spec.Template.Spec.Volumes {
+// jobVolumeRefs returns the references to the ConfigMaps and Secrets which are
+// used by the volumes of the job. This includes projected volumes and
+// the Secrets which are used by volume plugins to mount a volume. When a volume
+// only projects specific keys through its items, only the keys of those values
+// are used.
+func jobVolumeRefs(ns string, pod podSpec) []Reference {
+	refs := []Reference{}
+	for _, volume := range pod.Volumes {
 		if volume.ConfigMap != nil {
-			key := fmt.Sprintf("ConfigMap/%s/%s", ns, volume.ConfigMap.LocalObjectReference.Name)
-			if val, ok := config[key]; ok {
-				hashes = append(hashes, val)
-			}
+			refs = append(refs, volumeRefs("ConfigMap", ns, volume.ConfigMap.LocalObjectReference.Name, volume.ConfigMap.Items, isOptional(volume.ConfigMap.Optional))...)
 		}
 
 		if volume.Secret != nil {
-			key := fmt.Sprintf("Secret/%s/%s", ns, volume.Secret.SecretName)
-			if val, ok := config[key]; ok {
-				hashes = append(hashes, val)
+			refs = append(refs, volumeRefs("Secret", ns, volume.Secret.SecretName, volume.Secret.Items, isOptional(volume.Secret.Optional))...)
+		}
+
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if cm := source.ConfigMap; cm != nil {
+					refs = append(refs, volumeRefs("ConfigMap", ns, cm.LocalObjectReference.Name, cm.Items, isOptional(cm.Optional))...)
+				}
+
+				if sr := source.Secret; sr != nil {
+					refs = append(refs, volumeRefs("Secret", ns, sr.LocalObjectReference.Name, sr.Items, isOptional(sr.Optional))...)
+				}
 			}
 		}
+
+		for _, name := range volumeSecretNames(volume) {
+			refs = append(refs, volumeRefs("Secret", ns, name, nil, false)...)
+		}
+	}
+
+	return refs
+}
+
+// volumeSecretNames returns the names of the Secrets which are used by a volume
+// plugin to mount the volume, like the credentials of a CSI driver.
+func volumeSecretNames(volume cv1.Volume) []string {
+	refs := []*cv1.LocalObjectReference{}
+	if v := volume.CSI; v != nil {
+		refs = append(refs, v.NodePublishSecretRef)
+	}
+	if v := volume.CephFS; v != nil {
+		refs = append(refs, v.SecretRef)
+	}
+	if v := volume.Cinder; v != nil {
+		refs = append(refs, v.SecretRef)
+	}
+	if v := volume.FlexVolume; v != nil {
+		refs = append(refs, v.SecretRef)
+	}
+	if v := volume.ISCSI; v != nil {
+		refs = append(refs, v.SecretRef)
+	}
+	if v := volume.RBD; v != nil {
+		refs = append(refs, v.SecretRef)
+	}
+	if v := volume.ScaleIO; v != nil {
+		refs = append(refs, v.SecretRef)
+	}
+	if v := volume.StorageOS; v != nil {
+		refs = append(refs, v.SecretRef)
+	}
+
+	names := []string{}
+	for _, ref := range refs {
+		if ref != nil && ref.Name != "" {
+			names = append(names, ref.Name)
+		}
+	}
+
+	if v := volume.AzureFile; v != nil && v.SecretName != "" {
+		names = append(names, v.SecretName)
 	}
 
-	return hashes
+	return names
 }
 
-func encodeHashSlice(hashes []string) (string, error) {
+// volumeRefs returns the references for a ConfigMap or Secret which is mounted
+// as a volume. Without items, the full object is referenced.
+func volumeRefs(kind, ns, name string, items []cv1.KeyToPath, optional bool) []Reference {
+	if len(items) == 0 {
+		return []Reference{{Key: configKey(kind, ns, name, ""), Optional: optional}}
+	}
+
+	refs := []Reference{}
+	for _, item := range items {
+		refs = append(refs, Reference{Key: configKey(kind, ns, name, item.Key), Optional: optional})
+	}
+
+	return refs
+}
+
+// checkReferences reports the references of the job which could not be
+// resolved. Without StrictRefs a warning is logged, with StrictRefs an error
+// is returned. Optional references are left out of the report.
+func checkReferences(key string, hash jobHash, opts Options) error {
+	var missing []string
+	for _, ref := range hash.Unresolved {
+		if !ref.Optional {
+			missing = append(missing, ref.Key)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if opts.StrictRefs {
+		return fmt.Errorf("job '%s' references resources which are not part of the input: %s", key, strings.Join(missing, ", "))
+	}
+
+	log.Printf("Job '%s' references resources which are not part of the input: %s", key, strings.Join(missing, ", "))
+	return nil
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+func encodeHashSlice(hashes []string) (jobHash, error) {
 	joinedString := strings.Join(hashes[:], "")
-	return encodeHash(fmt.Sprintf("%x", sha256.Sum256([]byte(joinedString))))
+	full := fmt.Sprintf("%x", sha256.Sum256([]byte(joinedString)))
+
+	short, err := encodeHash(full)
+	if err != nil {
+		return jobHash{}, err
+	}
+
+	return jobHash{Full: full, Short: short}, nil
 }
 
 // encodeHash extracts the first 40 bits of the hash from the hex string
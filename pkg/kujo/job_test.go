@@ -1,6 +1,8 @@
 package kujo
 
 import (
+	"bytes"
+	"io/ioutil"
 	"os"
 	"testing"
 
@@ -8,6 +10,8 @@ import (
 	v1 "k8s.io/api/batch/v1"
 	cv1 "k8s.io/api/core/v1"
 	mv1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 func TestJobSlice(t *testing.T) {
@@ -112,7 +116,7 @@ func TestHashedJobs(t *testing.T) {
 				},
 			},
 			list: map[string]string{
-				"default/foo": "b6k5k6d596",
+				"default/foo": "2675c2d28d",
 			},
 		},
 		"with existing configmap linked": {
@@ -145,7 +149,7 @@ func TestHashedJobs(t *testing.T) {
 				"ConfigMap/default/perl-job-config": "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
 			},
 			list: map[string]string{
-				"default/foo": "b6k5k6d596",
+				"default/foo": "hm595gdbbf",
 			},
 		},
 		"with existing secret linked": {
@@ -187,7 +191,7 @@ func TestHashedJobs(t *testing.T) {
 				"ConfigMap/default/perl-job-config": "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
 			},
 			list: map[string]string{
-				"default/foo": "d9dkck4b5b",
+				"default/foo": "5fcgtgdh25",
 			},
 		},
 		"with existing configmap and secret linked": {
@@ -219,7 +223,7 @@ func TestHashedJobs(t *testing.T) {
 				"ConfigMap/default/perl-job-config": "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
 			},
 			list: map[string]string{
-				"default/foo": "cg8g5t8m84",
+				"default/foo": "m2d8m542md",
 			},
 		},
 		"without env linked": {
@@ -263,7 +267,7 @@ func TestHashedJobs(t *testing.T) {
 				},
 			},
 			list: map[string]string{
-				"default/foo": "hh9ktgc5mg",
+				"default/foo": "9tb2558g52",
 			},
 		},
 
@@ -298,7 +302,7 @@ func TestHashedJobs(t *testing.T) {
 				},
 			},
 			list: map[string]string{
-				"default/foo": "fffg62dhc8",
+				"default/foo": "ggt9dg47fk",
 			},
 			config: map[string]string{
 				"Secret/default/mysecret":           "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
@@ -337,7 +341,7 @@ func TestHashedJobs(t *testing.T) {
 				},
 			},
 			list: map[string]string{
-				"default/foo": "2c22g5g4tt",
+				"default/foo": "hg8c27mf4m",
 			},
 			config: map[string]string{
 				"Secret/default/mysecret":           "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
@@ -386,7 +390,7 @@ func TestHashedJobs(t *testing.T) {
 				},
 			},
 			list: map[string]string{
-				"default/foo": "dh98674kcb",
+				"default/foo": "tkbbf7mm4t",
 			},
 			config: map[string]string{
 				"Secret/default/mysecret":           "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
@@ -429,7 +433,7 @@ func TestHashedJobs(t *testing.T) {
 				},
 			},
 			list: map[string]string{
-				"default/foo": "mt8f6tcg88",
+				"default/foo": "thgt654ghh",
 			},
 		},
 
@@ -461,7 +465,7 @@ func TestHashedJobs(t *testing.T) {
 				},
 			},
 			list: map[string]string{
-				"default/foo": "6hk9ttb952",
+				"default/foo": "km4h4f2f2h",
 			},
 			config: map[string]string{
 				"Secret/default/mysecret":           "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
@@ -497,7 +501,7 @@ func TestHashedJobs(t *testing.T) {
 				},
 			},
 			list: map[string]string{
-				"default/foo": "mkh9h2ftf6",
+				"default/foo": "k66hmkk552",
 			},
 			config: map[string]string{
 				"Secret/default/mysecret":           "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
@@ -540,13 +544,538 @@ func TestHashedJobs(t *testing.T) {
 				},
 			},
 			list: map[string]string{
-				"default/foo": "h9gfkdb5hm",
+				"default/foo": "m2ctfkd8hd",
 			},
 			config: map[string]string{
 				"Secret/default/mysecret":           "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
 				"ConfigMap/default/perl-job-config": "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
 			},
 		},
+
+		"with env keys": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name: "foo",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Containers: []cv1.Container{
+									{
+										Env: []cv1.EnvVar{
+											{
+												Name: "my-cm-env-var",
+												ValueFrom: &cv1.EnvVarSource{
+													ConfigMapKeyRef: &cv1.ConfigMapKeySelector{
+														LocalObjectReference: cv1.LocalObjectReference{
+															Name: "perl-job-config",
+														},
+														Key: "job.data",
+													},
+												},
+											},
+											{
+												Name: "my-secret-env-var",
+												ValueFrom: &cv1.EnvVarSource{
+													SecretKeyRef: &cv1.SecretKeySelector{
+														LocalObjectReference: cv1.LocalObjectReference{
+															Name: "mysecret",
+														},
+														Key: "username",
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "hm7gg7d7tc",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with env keys and other keys changed": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name: "foo",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Containers: []cv1.Container{
+									{
+										Env: []cv1.EnvVar{
+											{
+												Name: "my-cm-env-var",
+												ValueFrom: &cv1.EnvVarSource{
+													ConfigMapKeyRef: &cv1.ConfigMapKeySelector{
+														LocalObjectReference: cv1.LocalObjectReference{
+															Name: "perl-job-config",
+														},
+														Key: "job.data",
+													},
+												},
+											},
+											{
+												Name: "my-secret-env-var",
+												ValueFrom: &cv1.EnvVarSource{
+													SecretKeyRef: &cv1.SecretKeySelector{
+														LocalObjectReference: cv1.LocalObjectReference{
+															Name: "mysecret",
+														},
+														Key: "username",
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "hm7gg7d7tc",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "3bd33833f2154454f6bc666cc37f5d49699489cc3d019cdc4748a41c39e2654b",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "830efe400087768d8c955ed99e771b742466588423af1b3aa52a4a12391ed50e",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with volume items": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Volumes: []cv1.Volume{
+									{
+										Name: "linked-secret",
+										VolumeSource: cv1.VolumeSource{
+											Secret: &cv1.SecretVolumeSource{
+												SecretName: "mysecret",
+												Items: []cv1.KeyToPath{
+													{
+														Key:  "username",
+														Path: "username.txt",
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "dh2m7c265k",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with volume items and other keys changed": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Volumes: []cv1.Volume{
+									{
+										Name: "linked-secret",
+										VolumeSource: cv1.VolumeSource{
+											Secret: &cv1.SecretVolumeSource{
+												SecretName: "mysecret",
+												Items: []cv1.KeyToPath{
+													{
+														Key:  "username",
+														Path: "username.txt",
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "dh2m7c265k",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "3bd33833f2154454f6bc666cc37f5d49699489cc3d019cdc4748a41c39e2654b",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "830efe400087768d8c955ed99e771b742466588423af1b3aa52a4a12391ed50e",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with projected configmap": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Volumes: []cv1.Volume{
+									{
+										Name: "linked-volume",
+										VolumeSource: cv1.VolumeSource{
+											Projected: &cv1.ProjectedVolumeSource{
+												Sources: []cv1.VolumeProjection{
+													{
+														ConfigMap: &cv1.ConfigMapProjection{
+															LocalObjectReference: cv1.LocalObjectReference{
+																Name: "perl-job-config",
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "mm29bb69b5",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with projected secret items": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Volumes: []cv1.Volume{
+									{
+										Name: "linked-volume",
+										VolumeSource: cv1.VolumeSource{
+											Projected: &cv1.ProjectedVolumeSource{
+												Sources: []cv1.VolumeProjection{
+													{
+														Secret: &cv1.SecretProjection{
+															LocalObjectReference: cv1.LocalObjectReference{
+																Name: "mysecret",
+															},
+															Items: []cv1.KeyToPath{
+																{
+																	Key:  "username",
+																	Path: "username.txt",
+																},
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "g8hf22f7gb",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with projected configmap and secret": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Volumes: []cv1.Volume{
+									{
+										Name: "linked-volume",
+										VolumeSource: cv1.VolumeSource{
+											Projected: &cv1.ProjectedVolumeSource{
+												Sources: []cv1.VolumeProjection{
+													{
+														ConfigMap: &cv1.ConfigMapProjection{
+															LocalObjectReference: cv1.LocalObjectReference{
+																Name: "perl-job-config",
+															},
+														},
+													},
+													{
+														Secret: &cv1.SecretProjection{
+															LocalObjectReference: cv1.LocalObjectReference{
+																Name: "mysecret",
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "k9h5bkfkt6",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with csi node publish secret": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Volumes: []cv1.Volume{
+									{
+										Name: "linked-volume",
+										VolumeSource: cv1.VolumeSource{
+											CSI: &cv1.CSIVolumeSource{
+												Driver: "secrets-store.csi.k8s.io",
+												NodePublishSecretRef: &cv1.LocalObjectReference{
+													Name: "mysecret",
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "t7bg7f8k96",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with azure file secret": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Volumes: []cv1.Volume{
+									{
+										Name: "linked-volume",
+										VolumeSource: cv1.VolumeSource{
+											AzureFile: &cv1.AzureFileVolumeSource{
+												SecretName: "mysecret",
+												ShareName:  "share",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "457httckh9",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with flex volume secret": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Volumes: []cv1.Volume{
+									{
+										Name: "linked-volume",
+										VolumeSource: cv1.VolumeSource{
+											FlexVolume: &cv1.FlexVolumeSource{
+												Driver: "example/driver",
+												SecretRef: &cv1.LocalObjectReference{
+													Name: "mysecret",
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "94h9g729fd",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with cephfs secret": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Volumes: []cv1.Volume{
+									{
+										Name: "linked-volume",
+										VolumeSource: cv1.VolumeSource{
+											CephFS: &cv1.CephFSVolumeSource{
+												Monitors: []string{"10.0.0.1:6789"},
+												SecretRef: &cv1.LocalObjectReference{
+													Name: "mysecret",
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "tkcgb98847",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
+
+		"with csi without secret": {
+			jobs: []v1.Job{
+				{
+					ObjectMeta: mv1.ObjectMeta{
+						Name:      "foo",
+						Namespace: "default",
+					},
+					Spec: v1.JobSpec{
+						Template: cv1.PodTemplateSpec{
+							Spec: cv1.PodSpec{
+								Volumes: []cv1.Volume{
+									{
+										Name: "linked-volume",
+										VolumeSource: cv1.VolumeSource{
+											CSI: &cv1.CSIVolumeSource{
+												Driver: "secrets-store.csi.k8s.io",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			list: map[string]string{
+				"default/foo": "kk7h548k27",
+			},
+			config: map[string]string{
+				"Secret/default/mysecret":                    "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+				"Secret/default/mysecret/username":           "8c6976e5b5410415bde908bd4dee15dfb167a9c873fc4bb8a81f6f2ab448a918",
+				"ConfigMap/default/perl-job-config":          "6b01af86bab978c892006d41097f29c7b040d459e6613fad29293c1d2c624046",
+				"ConfigMap/default/perl-job-config/job.data": "d70f537ddd1af125ef0c889b6d6c9acd2fc93576d4ed819e8d2070a2bc1054e9",
+			},
+		},
 	}
 
 	for name, tc := range tcs {
@@ -562,3 +1091,288 @@ func TestHashedJobs(t *testing.T) {
 		})
 	}
 }
+
+func TestHashJobsContainerLists(t *testing.T) {
+	tcs := map[string]struct {
+		field string
+	}{
+		"with init containers": {
+			field: "initContainers",
+		},
+		"with containers": {
+			field: "containers",
+		},
+		"with ephemeral containers": {
+			field: "ephemeralContainers",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			job := unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata": map[string]interface{}{
+					"name": "foo",
+				},
+				"spec": map[string]interface{}{
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							tc.field: []interface{}{
+								map[string]interface{}{
+									"name":  "credentials",
+									"image": "vault",
+									"envFrom": []interface{}{
+										map[string]interface{}{
+											"secretRef": map[string]interface{}{
+												"name": "mysecret",
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			}}
+
+			original, err := hashJobs([]unstructured.Unstructured{job}, map[string]string{
+				"Secret/default/mysecret": "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+			}, Options{})
+			if err != nil {
+				t.Fatalf("Expected no error hashing the jobs, got '%s'", err)
+			}
+
+			rotated, err := hashJobs([]unstructured.Unstructured{job}, map[string]string{
+				"Secret/default/mysecret": "3bd33833f2154454f6bc666cc37f5d49699489cc3d019cdc4748a41c39e2654b",
+			}, Options{})
+			if err != nil {
+				t.Fatalf("Expected no error hashing the jobs, got '%s'", err)
+			}
+
+			if original["default/foo"].Full == rotated["default/foo"].Full {
+				t.Errorf("Expected the hash to change when the secret changes, got '%s' for both", original["default/foo"].Short)
+			}
+		})
+	}
+}
+
+func TestUnresolvedReferences(t *testing.T) {
+	input, err := ioutil.ReadFile("testdata/refs-input.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error reading the input file, got '%s'", err)
+	}
+
+	explanations, err := Explain(bytes.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Expected no error explaining the input, got '%s'", err)
+	}
+
+	expected := []Reference{
+		{Key: "Secret/default/certs", Optional: true},
+		{Key: "Secret/default/credentials/username"},
+		{Key: "ConfigMap/default/overrides", Optional: true},
+	}
+	if !cmp.Equal(expected, explanations[0].Unresolved) {
+		t.Errorf("Expected unresolved references to match, got diff %s", cmp.Diff(expected, explanations[0].Unresolved))
+	}
+
+	tcs := map[string]struct {
+		input []byte
+		opts  Options
+		err   bool
+	}{
+		"with missing references": {
+			input: input,
+		},
+		"with missing references in strict mode": {
+			input: input,
+			opts:  Options{StrictRefs: true},
+			err:   true,
+		},
+		"with missing optional references in strict mode": {
+			input: bytes.Replace(input, []byte("key: username"), []byte("key: password"), 1),
+			opts:  Options{StrictRefs: true},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			_, err := Convert(bytes.NewReader(tc.input), tc.opts)
+			if tc.err != (err != nil) {
+				t.Errorf("Expected error to be %t, got '%v'", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestHashJobsCanonicalize(t *testing.T) {
+	written := v1.Job{
+		ObjectMeta: mv1.ObjectMeta{Name: "foo"},
+		Spec: v1.JobSpec{
+			Template: cv1.PodTemplateSpec{
+				Spec: cv1.PodSpec{
+					RestartPolicy: cv1.RestartPolicyNever,
+					Containers: []cv1.Container{
+						{Name: "foo", Image: "perl:5"},
+					},
+				},
+			},
+		},
+	}
+
+	exported := written.DeepCopy()
+	exported.Spec.Template.Spec.DNSPolicy = cv1.DNSClusterFirst
+	exported.Spec.Template.Spec.Containers[0].TerminationMessagePath = "/dev/termination-log"
+	exported.Spec.Template.Spec.Containers[0].ImagePullPolicy = cv1.PullIfNotPresent
+
+	referencing := written.DeepCopy()
+	referencing.Spec.Template.Spec.Containers[0].Env = []cv1.EnvVar{
+		{
+			Name: "A",
+			ValueFrom: &cv1.EnvVarSource{
+				ConfigMapKeyRef: &cv1.ConfigMapKeySelector{LocalObjectReference: cv1.LocalObjectReference{Name: "myconfig"}, Key: "a"},
+			},
+		},
+		{
+			Name: "B",
+			ValueFrom: &cv1.EnvVarSource{
+				SecretKeyRef: &cv1.SecretKeySelector{LocalObjectReference: cv1.LocalObjectReference{Name: "mysecret"}, Key: "b"},
+			},
+		},
+	}
+	referencing.Spec.Template.Spec.Volumes = []cv1.Volume{
+		{Name: "config", VolumeSource: cv1.VolumeSource{ConfigMap: &cv1.ConfigMapVolumeSource{LocalObjectReference: cv1.LocalObjectReference{Name: "myconfig"}}}},
+		{Name: "secret", VolumeSource: cv1.VolumeSource{Secret: &cv1.SecretVolumeSource{SecretName: "mysecret"}}},
+	}
+
+	reordered := referencing.DeepCopy()
+	env := reordered.Spec.Template.Spec.Containers[0].Env
+	env[0], env[1] = env[1], env[0]
+	volumes := reordered.Spec.Template.Spec.Volumes
+	volumes[0], volumes[1] = volumes[1], volumes[0]
+
+	config := map[string]string{
+		"ConfigMap/default/myconfig":   "0123456789",
+		"ConfigMap/default/myconfig/a": "1234567890",
+		"Secret/default/mysecret":      "2345678901",
+		"Secret/default/mysecret/b":    "3456789012",
+	}
+
+	tcs := map[string]struct {
+		from  *v1.Job
+		to    *v1.Job
+		opts  Options
+		equal bool
+	}{
+		"without canonicalization": {
+			from:  &written,
+			to:    exported,
+			opts:  Options{},
+			equal: false,
+		},
+		"with canonicalization": {
+			from:  &written,
+			to:    exported,
+			opts:  Options{Canonicalize: true},
+			equal: true,
+		},
+		"with reordered references without canonicalization": {
+			from:  referencing,
+			to:    reordered,
+			opts:  Options{},
+			equal: false,
+		},
+		"with reordered references with canonicalization": {
+			from:  referencing,
+			to:    reordered,
+			opts:  Options{Canonicalize: true},
+			equal: true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			from, err := hashJobs([]unstructured.Unstructured{unstructuredJob(t, tc.from)}, config, tc.opts)
+			if err != nil {
+				t.Fatalf("Expected no error, got '%s'", err)
+			}
+
+			to, err := hashJobs([]unstructured.Unstructured{unstructuredJob(t, tc.to)}, config, tc.opts)
+			if err != nil {
+				t.Fatalf("Expected no error, got '%s'", err)
+			}
+
+			fromHash, toHash := from["default/foo"], to["default/foo"]
+			if equal := fromHash.Full == toHash.Full; equal != tc.equal {
+				t.Errorf("Expected the hashes to be equal to be %t, got '%s' and '%s'", tc.equal, fromHash.Short, toHash.Short)
+			}
+
+			if len(fromHash.Unresolved) > 0 {
+				t.Errorf("Expected all references to resolve, got %v", fromHash.Unresolved)
+			}
+		})
+	}
+}
+
+func unstructuredJob(t *testing.T, job *v1.Job) unstructured.Unstructured {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(job)
+	if err != nil {
+		t.Fatalf("Expected no error converting the job, got '%s'", err)
+	}
+
+	return unstructured.Unstructured{Object: obj}
+}
+
+func TestHashJobsUnknownFields(t *testing.T) {
+	job := func(spec map[string]interface{}) unstructured.Unstructured {
+		spec["template"] = map[string]interface{}{
+			"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{
+						"name":  "migrate",
+						"image": "migrate",
+						"envFrom": []interface{}{
+							map[string]interface{}{
+								"secretRef": map[string]interface{}{
+									"name": "mysecret",
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		return unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "Job",
+			"metadata": map[string]interface{}{
+				"name": "foo",
+			},
+			"spec": spec,
+		}}
+	}
+
+	config := map[string]string{
+		"Secret/default/mysecret": "8ebf17fe046d11996943eee66edbe0a487cb0a7b75f34d3d469ab58649530fbd",
+	}
+
+	original, err := hashJobs([]unstructured.Unstructured{job(map[string]interface{}{})}, config, Options{})
+	if err != nil {
+		t.Fatalf("Expected no error hashing the jobs, got '%s'", err)
+	}
+
+	suspended, err := hashJobs([]unstructured.Unstructured{job(map[string]interface{}{"suspend": true, "completionMode": "Indexed"})}, config, Options{})
+	if err != nil {
+		t.Fatalf("Expected no error hashing the jobs, got '%s'", err)
+	}
+
+	if original["default/foo"].Full == suspended["default/foo"].Full {
+		t.Errorf("Expected the hash to change with fields unknown to the API, got '%s' for both", original["default/foo"].Short)
+	}
+
+	if len(suspended["default/foo"].Inputs) != 1 {
+		t.Errorf("Expected references to be discovered, got inputs '%v'", suspended["default/foo"].Inputs)
+	}
+}
@@ -0,0 +1,210 @@
+package kujo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// WorkloadKind describes a kind of resource which runs its pods once, like a
+// Job. Resources of a workload kind which opt in with the `kujo.sphc.io`
+// annotation are renamed to a unique name. The paths of the kind are written
+// as JSON pointers or dotted paths, see fieldPath, and traverse lists for all
+// their items.
+type WorkloadKind struct {
+	// APIVersions are the API versions, including the group, the kind is
+	// accepted in, like `batch/v1`.
+	APIVersions []string `yaml:"apiVersions" json:"apiVersions"`
+
+	// Kind is the kind of the resource.
+	Kind string `yaml:"kind" json:"kind"`
+
+	// PodSpecs are the paths of the objects which are shaped like a pod spec.
+	// Their containers, volumes and image pull secrets are used to find the
+	// ConfigMaps and Secrets the resource references.
+	PodSpecs []string `yaml:"podSpecs,omitempty" json:"podSpecs,omitempty"`
+
+	// Containers are the paths of containers, or lists of containers, which
+	// are not part of a pod spec.
+	Containers []string `yaml:"containers,omitempty" json:"containers,omitempty"`
+
+	// PodLabels are the paths of the labels which are set on the pods of the
+	// resource. The base name label is set on them next to the labels of the
+	// resource itself. The labels are created when they are missing, so these
+	// paths can't traverse lists.
+	PodLabels []string `yaml:"podLabels,omitempty" json:"podLabels,omitempty"`
+}
+
+// jobKind is the kind of batch/v1 Jobs. Unregistered resources, like the typed
+// jobs passed to HashedJobs, are treated as Jobs.
+var jobKind = WorkloadKind{
+	APIVersions: []string{"batch/v1"},
+	Kind:        "Job",
+	PodSpecs:    []string{"spec.template.spec"},
+	PodLabels:   []string{"spec.template.metadata.labels"},
+}
+
+// podKind is the kind of bare v1 Pods, which hold the pod spec at the top.
+var podKind = WorkloadKind{
+	APIVersions: []string{"v1"},
+	Kind:        "Pod",
+	PodSpecs:    []string{"spec"},
+}
+
+// DefaultWorkloadKinds are the built-in workload kinds: Jobs and bare Pods.
+var DefaultWorkloadKinds = []WorkloadKind{jobKind, podKind}
+
+// ReadWorkloadKinds reads a YAML or JSON file with a list of workload kinds.
+func ReadWorkloadKinds(path string) ([]WorkloadKind, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var kinds []WorkloadKind
+	if err := yaml.UnmarshalStrict(data, &kinds); err != nil {
+		return nil, fmt.Errorf("could not parse the workload kinds file '%s': %s", path, err)
+	}
+
+	for _, kind := range kinds {
+		if kind.Kind == "" || len(kind.APIVersions) == 0 {
+			return nil, fmt.Errorf("the workload kinds in '%s' need a kind and API versions", path)
+		}
+
+		if len(kind.PodSpecs) == 0 && len(kind.Containers) == 0 {
+			return nil, fmt.Errorf("the workload kind '%s' has no pod specs or containers", kind.Kind)
+		}
+
+		for _, paths := range [][]string{kind.PodSpecs, kind.Containers, kind.PodLabels} {
+			for _, path := range paths {
+				if _, err := fieldPath(path); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	return kinds, nil
+}
+
+// workloadKinds returns the built-in and the configured workload kinds.
+func (o Options) workloadKinds() []WorkloadKind {
+	return append(append([]WorkloadKind{}, DefaultWorkloadKinds...), o.WorkloadKinds...)
+}
+
+// workloadKind returns the workload kind of the resource. Configured kinds
+// take precedence over the built-in kinds.
+func workloadKind(un unstructured.Unstructured, opts Options) (WorkloadKind, bool) {
+	kinds := opts.workloadKinds()
+	for i := len(kinds) - 1; i >= 0; i-- {
+		if kinds[i].matches(un) {
+			return kinds[i], true
+		}
+	}
+
+	return WorkloadKind{}, false
+}
+
+// matches reports if the resource is of this kind.
+func (k WorkloadKind) matches(un unstructured.Unstructured) bool {
+	if un.GetKind() != k.Kind {
+		return false
+	}
+
+	for _, version := range k.APIVersions {
+		if version == un.GetAPIVersion() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isWorkloadResource reports if the resource is of a workload kind and opted
+// in to being renamed.
+func isWorkloadResource(un unstructured.Unstructured, opts Options) bool {
+	if _, ok := workloadKind(un, opts); !ok {
+		return false
+	}
+
+	return isOptedIn(un)
+}
+
+// podSpecs returns the pod specs of the resource. The specs share their data
+// with the resource.
+func (k WorkloadKind) podSpecs(obj map[string]interface{}) []map[string]interface{} {
+	var specs []map[string]interface{}
+	for _, path := range k.PodSpecs {
+		specs = append(specs, nestedMaps(obj, path)...)
+	}
+
+	return specs
+}
+
+// containers returns the containers of the resource which are not part of a
+// pod spec. The containers share their data with the resource.
+func (k WorkloadKind) containers(obj map[string]interface{}) []map[string]interface{} {
+	var containers []map[string]interface{}
+	for _, path := range k.Containers {
+		containers = append(containers, nestedMaps(obj, path)...)
+	}
+
+	return containers
+}
+
+// allContainers returns all containers of the resource, both the ones of its
+// pod specs and the ones outside of them.
+func (k WorkloadKind) allContainers(obj map[string]interface{}) []map[string]interface{} {
+	var containers []map[string]interface{}
+	for _, pod := range k.podSpecs(obj) {
+		for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+			containers = append(containers, mapsOf(pod[field])...)
+		}
+	}
+
+	return append(containers, k.containers(obj)...)
+}
+
+// nestedMaps returns the objects at the path. Lists along the path are
+// traversed for all their items, unless the path holds the index of a single
+// item. A list at the end of the path returns all its objects.
+func nestedMaps(obj map[string]interface{}, path string) []map[string]interface{} {
+	segments, err := fieldPath(path)
+	if err != nil {
+		return nil
+	}
+
+	var maps []map[string]interface{}
+	var walk func(value interface{}, segments []string)
+	walk = func(value interface{}, segments []string) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if len(segments) == 0 {
+				maps = append(maps, v)
+				return
+			}
+			walk(v[segments[0]], segments[1:])
+		case []interface{}:
+			if len(segments) > 0 {
+				if segments[0] == "*" {
+					segments = segments[1:]
+				} else if i, err := strconv.Atoi(segments[0]); err == nil {
+					if i >= 0 && i < len(v) {
+						walk(v[i], segments[1:])
+					}
+					return
+				}
+			}
+
+			for _, item := range v {
+				walk(item, segments)
+			}
+		}
+	}
+	walk(obj, segments)
+
+	return maps
+}
@@ -0,0 +1,175 @@
+package kujo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadWorkloadKinds(t *testing.T) {
+	tcs := map[string]struct {
+		data  string
+		kinds []WorkloadKind
+		err   bool
+	}{
+		"with a kind": {
+			data: "- apiVersions: [example.com/v1]\n  kind: Task\n  podSpecs: [spec.pod]\n  containers: [spec.steps]\n",
+			kinds: []WorkloadKind{
+				{APIVersions: []string{"example.com/v1"}, Kind: "Task", PodSpecs: []string{"spec.pod"}, Containers: []string{"spec.steps"}},
+			},
+		},
+		"without a kind": {
+			data: "- apiVersions: [example.com/v1]\n  podSpecs: [spec.pod]\n",
+			err:  true,
+		},
+		"without pod specs or containers": {
+			data: "- apiVersions: [example.com/v1]\n  kind: Task\n",
+			err:  true,
+		},
+		"with an invalid path": {
+			data: "- apiVersions: [example.com/v1]\n  kind: Task\n  podSpecs: [spec..pod]\n",
+			err:  true,
+		},
+		"with an unknown field": {
+			data: "- apiVersions: [example.com/v1]\n  kind: Task\n  podSpec: spec.pod\n",
+			err:  true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			file, err := ioutil.TempFile("", "kinds")
+			if err != nil {
+				t.Fatalf("Expected no error creating the file, got '%s'", err)
+			}
+			defer os.Remove(file.Name())
+
+			if _, err := file.WriteString(tc.data); err != nil {
+				t.Fatalf("Expected no error writing the file, got '%s'", err)
+			}
+			file.Close()
+
+			kinds, err := ReadWorkloadKinds(file.Name())
+			if tc.err != (err != nil) {
+				t.Errorf("Expected error to be %t, got '%v'", tc.err, err)
+			}
+
+			// we've got an error, don't run further tests
+			if err != nil {
+				return
+			}
+
+			if !cmp.Equal(tc.kinds, kinds) {
+				t.Errorf("Expected kinds to match, got diff %s", cmp.Diff(tc.kinds, kinds))
+			}
+		})
+	}
+}
+
+func TestWorkloadKinds(t *testing.T) {
+	kinds, err := ReadWorkloadKinds("../../_examples/kinds.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error reading the example kinds, got '%s'", err)
+	}
+
+	input, err := ioutil.ReadFile("testdata/kinds-input.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error reading the input file, got '%s'", err)
+	}
+
+	tcs := map[string]struct {
+		opts  Options
+		names []string
+	}{
+		"with the built-in kinds": {
+			names: []string{"settings", "backfill-dm7hkg4hkt", "report", "build", "skipped"},
+		},
+		"with the example kinds": {
+			opts:  Options{WorkloadKinds: kinds},
+			names: []string{"settings", "backfill-dm7hkg4hkt", "report-8g86hc9bhg", "build-dtmg785b62", "skipped"},
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			output, err := Convert(bytes.NewReader(input), tc.opts)
+			if err != nil {
+				t.Fatalf("Expected no error converting the input, got '%s'", err)
+			}
+
+			resources, err := ResourcesFromReader(bytes.NewReader(output))
+			if err != nil {
+				t.Fatalf("Expected no error reading the output, got '%s'", err)
+			}
+
+			var names []string
+			for _, rs := range resources {
+				names = append(names, rs.GetName())
+			}
+
+			if !cmp.Equal(tc.names, names) {
+				t.Errorf("Expected names to match, got diff %s", cmp.Diff(tc.names, names))
+			}
+		})
+	}
+
+	t.Run("with pod labels", func(t *testing.T) {
+		output, err := Convert(bytes.NewReader(input), Options{WorkloadKinds: kinds})
+		if err != nil {
+			t.Fatalf("Expected no error converting the input, got '%s'", err)
+		}
+
+		resources, err := ResourcesFromReader(bytes.NewReader(output))
+		if err != nil {
+			t.Fatalf("Expected no error reading the output, got '%s'", err)
+		}
+
+		label, _ := nestedValue(resources[2], []string{"spec", "podMetadata", "labels", baseNameLabelKey})
+		if label != "report" {
+			t.Errorf("Expected the pod label to be 'report', got '%s'", label)
+		}
+	})
+}
+
+func TestNestedMaps(t *testing.T) {
+	obj := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"templates": []interface{}{
+				map[string]interface{}{"container": map[string]interface{}{"name": "a"}},
+				map[string]interface{}{"script": map[string]interface{}{"name": "b"}},
+				map[string]interface{}{"container": map[string]interface{}{"name": "c"}},
+			},
+			"steps": []interface{}{
+				map[string]interface{}{"name": "d"},
+				map[string]interface{}{"name": "e"},
+			},
+		},
+	}
+
+	tcs := map[string]struct {
+		path  string
+		names []string
+	}{
+		"with a list along the path": {path: "spec.templates.container", names: []string{"a", "c"}},
+		"with an index":              {path: "spec.templates.2.container", names: []string{"c"}},
+		"with a list at the end":     {path: "spec.steps", names: []string{"d", "e"}},
+		"with a JSON pointer":        {path: "/spec/templates/*/script", names: []string{"b"}},
+		"with a missing field":       {path: "spec.podTemplate"},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			var names []string
+			for _, m := range nestedMaps(obj, tc.path) {
+				names = append(names, m["name"].(string))
+			}
+
+			if !cmp.Equal(tc.names, names) {
+				t.Errorf("Expected maps to match, got diff %s", cmp.Diff(tc.names, names))
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package kujo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// kubeconfig holds the parts of a kubeconfig file which are needed to resolve
+// the namespace of the current context.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Contexts       []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// KubeconfigPaths returns the paths of the kubeconfig files which are used by
+// kubectl. These are the files in the KUBECONFIG environment variable, or
+// `~/.kube/config` when it isn't set.
+func KubeconfigPaths() []string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		paths := []string{}
+		for _, path := range filepath.SplitList(env) {
+			if path != "" {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// KubeconfigNamespace reads the kubeconfig files at the given paths and
+// returns the namespace of their current context. The files are merged like
+// kubectl does: the first file which sets the current context wins, as does
+// the first file which defines a context with that name. An empty namespace
+// is returned when none of the files exist or the current context doesn't set
+// a namespace.
+func KubeconfigNamespace(paths []string) (string, error) {
+	var configs []kubeconfig
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		var cfg kubeconfig
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return "", err
+		}
+		configs = append(configs, cfg)
+	}
+
+	var current string
+	for _, cfg := range configs {
+		if cfg.CurrentContext != "" {
+			current = cfg.CurrentContext
+			break
+		}
+	}
+
+	if current == "" {
+		return "", nil
+	}
+
+	for _, cfg := range configs {
+		for _, ctx := range cfg.Contexts {
+			if ctx.Name == current {
+				return ctx.Context.Namespace, nil
+			}
+		}
+	}
+
+	return "", nil
+}
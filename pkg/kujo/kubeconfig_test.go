@@ -0,0 +1,45 @@
+package kujo
+
+import "testing"
+
+func TestKubeconfigNamespace(t *testing.T) {
+	tcs := map[string]struct {
+		fixtures  []string
+		namespace string
+	}{
+		"with a namespace in the current context": {
+			fixtures:  []string{"testdata/kubeconfig.yaml"},
+			namespace: "migrations",
+		},
+		"without a namespace in the current context": {
+			fixtures: []string{"testdata/kubeconfig-default.yaml"},
+		},
+		"without a kubeconfig file": {
+			fixtures: []string{"testdata/missing-kubeconfig.yaml"},
+		},
+		"with the context defined by a later file": {
+			fixtures:  []string{"testdata/kubeconfig-context.yaml", "testdata/kubeconfig.yaml"},
+			namespace: "migrations",
+		},
+		"with the current context of the first file": {
+			fixtures: []string{"testdata/kubeconfig-default.yaml", "testdata/kubeconfig.yaml"},
+		},
+		"with a missing file": {
+			fixtures:  []string{"testdata/missing-kubeconfig.yaml", "testdata/kubeconfig.yaml"},
+			namespace: "migrations",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ns, err := KubeconfigNamespace(tc.fixtures)
+			if err != nil {
+				t.Errorf("Expected no error reading the kubeconfig, got '%s'", err)
+			}
+
+			if ns != tc.namespace {
+				t.Errorf("Expected namespace '%s', got '%s'", tc.namespace, ns)
+			}
+		})
+	}
+}
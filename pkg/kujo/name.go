@@ -0,0 +1,120 @@
+package kujo
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// maxNameLength is the maximum length of a job name. The Job controller sets
+// the name of the job as the value of the `job-name` label on its pods, which
+// limits the name to the length of a DNS-1123 label.
+const maxNameLength = validation.DNS1123LabelMaxLength
+
+// nameTemplateAnnKey is the annotation which sets the name template for a
+// single job.
+const nameTemplateAnnKey = annKey + "/name-template"
+
+// DefaultNameTemplate is the template which is used for job names when no
+// other template is configured.
+const DefaultNameTemplate = "{{.Name}}-{{.ShortHash}}"
+
+// nameData are the fields which are available in a name template.
+type nameData struct {
+	// Name is the original name of the job.
+	Name string
+
+	// Namespace is the resolved namespace of the job.
+	Namespace string
+
+	// Hash is the full hex encoded hash of the job.
+	Hash string
+
+	// ShortHash is the encoded hash which is used as suffix by default.
+	ShortHash string
+
+	// Labels are the labels of the job.
+	Labels map[string]string
+}
+
+// jobName returns the unique name for a job based on its original name and
+// hash. The name is rendered from the name template annotation of the job,
+// the configured name template or DefaultNameTemplate, in that order.
+// When the unique name is too long, the original name is truncated so the
+// hash is always kept intact. With StrictNames set, an error is returned
+// instead.
+func jobName(job unstructured.Unstructured, ns string, hash jobHash, opts Options) (string, error) {
+	text := DefaultNameTemplate
+	if opts.NameTemplate != "" {
+		text = opts.NameTemplate
+	}
+
+	if ann, ok := job.GetAnnotations()[nameTemplateAnnKey]; ok {
+		text = ann
+	}
+
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("could not parse the name template: %s", err)
+	}
+
+	data := nameData{
+		Name:      job.GetName(),
+		Namespace: ns,
+		Hash:      hash.Full,
+		ShortHash: hash.Short,
+		Labels:    job.GetLabels(),
+	}
+
+	unique, err := renderName(tmpl, data)
+	if err != nil {
+		return "", err
+	}
+
+	if len(unique) > maxNameLength {
+		if opts.StrictNames {
+			return "", fmt.Errorf("the name '%s' is longer than %d characters", unique, maxNameLength)
+		}
+
+		long := unique
+		data.Name = truncateName(data.Name, len(data.Name)-(len(unique)-maxNameLength))
+		if unique, err = renderName(tmpl, data); err != nil {
+			return "", err
+		}
+		log.Printf("The name '%s' is longer than %d characters, truncating it to '%s'", long, maxNameLength, unique)
+	}
+
+	if errs := validation.IsDNS1123Label(unique); len(errs) > 0 {
+		return "", fmt.Errorf("the name '%s' is invalid: %s", unique, strings.Join(errs, ", "))
+	}
+
+	return unique, nil
+}
+
+func renderName(tmpl *template.Template, data nameData) (string, error) {
+	buf := bytes.NewBuffer([]byte{})
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("could not render the name template: %s", err)
+	}
+
+	return buf.String(), nil
+}
+
+// truncateName shortens the name to the given length. Trailing dashes and dots
+// are removed so the name can be joined with a suffix.
+func truncateName(name string, length int) string {
+	if length < 0 {
+		length = 0
+	}
+
+	if len(name) > length {
+		name = name[:length]
+	}
+
+	return strings.TrimRight(name, "-.")
+}
@@ -0,0 +1,108 @@
+package kujo
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestJobName(t *testing.T) {
+	hash := jobHash{
+		Full:  "30b6ad9a70c1b8d13c4c55f0d7fe2bb44a2e55c57f2f8dd0ad02fabc9e37d23a",
+		Short: "k86kg7tt2c",
+	}
+
+	tcs := map[string]struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		opts        Options
+		result      string
+		err         bool
+	}{
+		"with a short name": {
+			name:   "migrate",
+			result: "migrate-k86kg7tt2c",
+		},
+		"with a name of the maximum length": {
+			name:   "a-name-which-is-exactly-fifty-two-characters-long-ab",
+			result: "a-name-which-is-exactly-fifty-two-characters-long-ab-k86kg7tt2c",
+		},
+		"with a long name": {
+			name:   "a-name-which-is-a-lot-longer-than-fifty-two-characters-long",
+			result: "a-name-which-is-a-lot-longer-than-fifty-two-characte-k86kg7tt2c",
+		},
+		"with a long name ending in a dash": {
+			name:   "a-name-which-is-a-lot-longer-than-fifty-two-characters-",
+			result: "a-name-which-is-a-lot-longer-than-fifty-two-characte-k86kg7tt2c",
+		},
+		"with a long name truncated at a dash": {
+			name:   "a-name-which-is-a-lot-longer-than-fifty-two-charact-ers",
+			result: "a-name-which-is-a-lot-longer-than-fifty-two-charact-k86kg7tt2c",
+		},
+		"with a long name in strict mode": {
+			name: "a-name-which-is-a-lot-longer-than-fifty-two-characters-long",
+			opts: Options{StrictNames: true},
+			err:  true,
+		},
+		"with an invalid name": {
+			name: "Migrate",
+			err:  true,
+		},
+		"with a name template": {
+			name:   "migrate",
+			opts:   Options{NameTemplate: "{{.ShortHash}}-{{.Name}}"},
+			result: "k86kg7tt2c-migrate",
+		},
+		"with a name template using labels": {
+			name:   "migrate",
+			labels: map[string]string{"version": "42"},
+			opts:   Options{NameTemplate: `{{.Name}}-v{{index .Labels "version"}}-{{.ShortHash}}`},
+			result: "migrate-v42-k86kg7tt2c",
+		},
+		"with a name template annotation": {
+			name:        "migrate",
+			annotations: map[string]string{"kujo.sphc.io/name-template": "{{.Name}}-{{.Namespace}}-{{.ShortHash}}"},
+			opts:        Options{NameTemplate: "{{.ShortHash}}-{{.Name}}"},
+			result:      "migrate-default-k86kg7tt2c",
+		},
+		"with a name template which is always too long": {
+			name: "a-name-which-is-a-lot-longer-than-fifty-two-characters-long",
+			opts: Options{NameTemplate: "{{.Name}}-{{.Hash}}"},
+			err:  true,
+		},
+		"with an invalid name template": {
+			name: "migrate",
+			opts: Options{NameTemplate: "{{.Name"},
+			err:  true,
+		},
+		"with an unknown field in the name template": {
+			name: "migrate",
+			opts: Options{NameTemplate: "{{.Unknown}}"},
+			err:  true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			job := unstructured.Unstructured{}
+			job.SetName(tc.name)
+			job.SetLabels(tc.labels)
+			job.SetAnnotations(tc.annotations)
+
+			result, err := jobName(job, "default", hash, tc.opts)
+			if tc.err != (err != nil) {
+				t.Errorf("Expected error to be %t, got '%v'", tc.err, err)
+			}
+
+			// we've got an error, don't run further tests
+			if err != nil {
+				return
+			}
+
+			if result != tc.result {
+				t.Errorf("Expected name '%s', got '%s'", tc.result, result)
+			}
+		})
+	}
+}
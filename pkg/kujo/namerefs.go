@@ -0,0 +1,156 @@
+package kujo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// NameReference is a field of a resource which holds the name of a Job. When
+// the Job is renamed, the field is updated to its unique name.
+type NameReference struct {
+	// Group is the API group of the referring resource. When empty, the
+	// resource matches by its kind in any group.
+	Group string
+
+	// Kind is the kind of the referring resource.
+	Kind string
+
+	// Path is the path of the field as a JSON pointer or dotted path, see
+	// fieldPath. Lists are traversed for all their items, a field which holds
+	// a list of names has all of them updated.
+	Path string
+}
+
+// DefaultNameReferences are the built-in fields which refer to Jobs by name,
+// mostly selectors on the `job-name` label which the Job controller sets on
+// its pods.
+var DefaultNameReferences = []NameReference{
+	{Kind: "NetworkPolicy", Path: "spec.podSelector.matchLabels.job-name"},
+	{Kind: "NetworkPolicy", Path: "spec.ingress.from.podSelector.matchLabels.job-name"},
+	{Kind: "NetworkPolicy", Path: "spec.egress.to.podSelector.matchLabels.job-name"},
+	{Kind: "NetworkPolicy", Path: "/spec/podSelector/matchLabels/batch.kubernetes.io~1job-name"},
+	{Kind: "NetworkPolicy", Path: "/spec/ingress/from/podSelector/matchLabels/batch.kubernetes.io~1job-name"},
+	{Kind: "NetworkPolicy", Path: "/spec/egress/to/podSelector/matchLabels/batch.kubernetes.io~1job-name"},
+	{Kind: "PodDisruptionBudget", Path: "spec.selector.matchLabels.job-name"},
+	{Kind: "PodDisruptionBudget", Path: "/spec/selector/matchLabels/batch.kubernetes.io~1job-name"},
+	{Kind: "Service", Path: "spec.selector.job-name"},
+	{Kind: "Service", Path: "/spec/selector/batch.kubernetes.io~1job-name"},
+}
+
+// ParseNameReference parses a name reference written as `Kind:path` or
+// `group/Kind:path`.
+func ParseNameReference(value string) (NameReference, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return NameReference{}, fmt.Errorf("the name reference '%s' is not written as Kind:path or group/Kind:path", value)
+	}
+
+	ref := NameReference{Kind: parts[0], Path: parts[1]}
+	if i := strings.LastIndex(ref.Kind, "/"); i != -1 {
+		ref.Group, ref.Kind = ref.Kind[:i], ref.Kind[i+1:]
+	}
+
+	if ref.Kind == "" {
+		return NameReference{}, fmt.Errorf("the name reference '%s' is not written as Kind:path or group/Kind:path", value)
+	}
+
+	if _, err := fieldPath(ref.Path); err != nil {
+		return NameReference{}, err
+	}
+
+	return ref, nil
+}
+
+// matches reports if the name reference applies to the resource.
+func (r NameReference) matches(un unstructured.Unstructured) bool {
+	if r.Kind != un.GetKind() {
+		return false
+	}
+
+	return r.Group == "" || r.Group == un.GroupVersionKind().Group
+}
+
+// renameJobRefs rewrites the fields of the name references which hold the
+// original name of a renamed job. Only resources in the namespace of the job
+// are updated. Resources of other workload kinds are not referenced through
+// the name reference table.
+func renameJobRefs(resourceList []unstructured.Unstructured, renamed []renamedJob, opts Options) error {
+	if len(renamed) == 0 {
+		return nil
+	}
+
+	names := map[string]string{}
+	for _, job := range renamed {
+		if job.kind.Kind != jobKind.Kind {
+			continue
+		}
+		names[fmt.Sprintf("%s/%s", job.namespace, job.original)] = job.name
+	}
+
+	refs := append(append([]NameReference{}, DefaultNameReferences...), opts.NameReferences...)
+	for _, ref := range refs {
+		segments, err := fieldPath(ref.Path)
+		if err != nil {
+			return err
+		}
+
+		for _, rs := range resourceList {
+			if !ref.matches(rs) {
+				continue
+			}
+
+			ns := opts.namespace(rs.GetNamespace())
+			renameField(rs.Object, segments, func(name string) (string, bool) {
+				renamed, ok := names[fmt.Sprintf("%s/%s", ns, name)]
+				return renamed, ok
+			})
+		}
+	}
+
+	return nil
+}
+
+// renameField replaces the names at the path in the object with the name
+// returned by rename. Lists are traversed for all their items, unless the
+// path holds the index of a single item.
+func renameField(obj interface{}, segments []string, rename func(string) (string, bool)) interface{} {
+	if len(segments) == 0 {
+		switch v := obj.(type) {
+		case string:
+			if name, ok := rename(v); ok {
+				return name
+			}
+		case []interface{}:
+			for i := range v {
+				v[i] = renameField(v[i], nil, rename)
+			}
+		}
+
+		return obj
+	}
+
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		if value, ok := v[segments[0]]; ok {
+			v[segments[0]] = renameField(value, segments[1:], rename)
+		}
+	case []interface{}:
+		if segments[0] == "*" {
+			segments = segments[1:]
+		} else if i, err := strconv.Atoi(segments[0]); err == nil {
+			if i >= 0 && i < len(v) {
+				v[i] = renameField(v[i], segments[1:], rename)
+			}
+			return v
+		}
+
+		for i := range v {
+			v[i] = renameField(v[i], segments, rename)
+		}
+	}
+
+	return obj
+}
@@ -0,0 +1,132 @@
+package kujo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseNameReference(t *testing.T) {
+	tcs := map[string]struct {
+		value string
+		ref   NameReference
+		err   bool
+	}{
+		"with a kind": {
+			value: "Service:spec.selector.job-name",
+			ref:   NameReference{Kind: "Service", Path: "spec.selector.job-name"},
+		},
+		"with a group": {
+			value: "example.com/Database:/spec/waitFor/job",
+			ref:   NameReference{Group: "example.com", Kind: "Database", Path: "/spec/waitFor/job"},
+		},
+		"without a path": {
+			value: "Service:",
+			err:   true,
+		},
+		"without a kind": {
+			value: "example.com/:spec.job",
+			err:   true,
+		},
+		"with an empty segment": {
+			value: "Service:spec..job-name",
+			err:   true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			ref, err := ParseNameReference(tc.value)
+			if tc.err != (err != nil) {
+				t.Errorf("Expected error to be %t, got '%v'", tc.err, err)
+			}
+
+			// we've got an error, don't run further tests
+			if err != nil {
+				return
+			}
+
+			if !cmp.Equal(tc.ref, ref) {
+				t.Errorf("Expected name reference to match, got diff %s", cmp.Diff(tc.ref, ref))
+			}
+		})
+	}
+}
+
+func TestRenameJobRefs(t *testing.T) {
+	input, err := ioutil.ReadFile("testdata/namerefs-input.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error reading the input file, got '%s'", err)
+	}
+
+	opts := Options{
+		NameReferences: []NameReference{{Group: "example.com", Kind: "Deployment", Path: "spec.waitFor.jobs"}},
+	}
+
+	output, err := Convert(bytes.NewReader(input), opts)
+	if err != nil {
+		t.Fatalf("Expected no error converting the input, got '%s'", err)
+	}
+
+	resources, err := ResourcesFromReader(bytes.NewReader(output))
+	if err != nil {
+		t.Fatalf("Expected no error reading the output, got '%s'", err)
+	}
+
+	name := resources[0].GetName()
+	if name == "migrate" {
+		t.Fatalf("Expected the job to be renamed, got '%s'", name)
+	}
+
+	tcs := map[string]struct {
+		index    int
+		path     []string
+		expected string
+	}{
+		"with a pod selector": {
+			index:    1,
+			path:     []string{"spec", "podSelector", "matchLabels", "job-name"},
+			expected: name,
+		},
+		"with a JSON pointer in a list": {
+			index:    1,
+			path:     []string{"spec", "egress", "0", "to", "0", "podSelector", "matchLabels", "batch.kubernetes.io/job-name"},
+			expected: name,
+		},
+		"with another job": {
+			index:    1,
+			path:     []string{"spec", "egress", "0", "to", "1", "podSelector", "matchLabels", "job-name"},
+			expected: "other",
+		},
+		"with another namespace": {
+			index:    2,
+			path:     []string{"spec", "selector", "matchLabels", "job-name"},
+			expected: "migrate",
+		},
+		"with a configured reference": {
+			index:    3,
+			path:     []string{"spec", "waitFor", "jobs", "0"},
+			expected: name,
+		},
+		"with a list of names": {
+			index:    3,
+			path:     []string{"spec", "waitFor", "jobs", "1"},
+			expected: "seed",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			value, ok := nestedValue(resources[tc.index], tc.path)
+			if !ok {
+				t.Fatalf("Expected a value at %v", tc.path)
+			}
+
+			if value != tc.expected {
+				t.Errorf("Expected reference to be '%s', got '%s'", tc.expected, value)
+			}
+		})
+	}
+}
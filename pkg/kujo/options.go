@@ -0,0 +1,98 @@
+package kujo
+
+// Options configures how resources are converted.
+type Options struct {
+	// FlattenLists outputs the items of List objects as separate documents
+	// instead of reassembling them into their List.
+	FlattenLists bool
+
+	// PreserveFormatting only changes the values which are updated in the
+	// original input, leaving comments, the order of keys and quoting of all
+	// other values intact.
+	PreserveFormatting bool
+
+	// Namespace is used for objects which don't have a namespace set when
+	// matching Jobs with their configuration. When empty, `default` is used.
+	Namespace string
+
+	// SetNamespace sets the resolved namespace on all namespaced objects which
+	// don't have a namespace set.
+	SetNamespace bool
+
+	// StrictNames returns an error when the unique name of a job is too long,
+	// instead of truncating the original name.
+	StrictNames bool
+
+	// NameTemplate is the Go template which is used to render the unique name
+	// of a job. Jobs can overwrite it with the `kujo.sphc.io/name-template`
+	// annotation. When empty, DefaultNameTemplate is used.
+	NameTemplate string
+
+	// StrictRefs returns an error when a job references a ConfigMap or Secret,
+	// or a value of it, which is not part of the input. References which are
+	// marked as optional are always allowed.
+	StrictRefs bool
+
+	// Canonicalize brings the job specification into a canonical form before
+	// it's hashed, so specifications which only differ in formatting result
+	// in the same name. Quantities are normalized, values which are equal to
+	// their default are removed and lists of which the order has no meaning
+	// are sorted.
+	Canonicalize bool
+
+	// HashExclude are the paths of the fields which are excluded from the
+	// hash of every job, as JSON pointers or dotted paths starting with
+	// `spec`. Jobs can exclude more fields with the
+	// `kujo.sphc.io/hash-exclude` annotation.
+	HashExclude []string
+
+	// BaseDir is the directory the paths of the `kujo.sphc.io/hash-paths` and
+	// `kujo.sphc.io/hash-git-path` annotations are resolved in. When empty,
+	// the working directory is used.
+	BaseDir string
+
+	// ImageLock maps image references to their digest. The digests of the
+	// images a job uses are folded into its hash, so pushing a new image for
+	// the same tag changes the name of the job. See ReadImageLock.
+	ImageLock map[string]string
+
+	// PinImages replaces the images of renamed jobs which are part of the
+	// ImageLock with a reference to their digest.
+	PinImages bool
+
+	// SuffixConfig renames all ConfigMaps and Secrets to a name suffixed with
+	// the hash of their content, and rewrites the references to them in the
+	// pod specs of the input. Objects can opt in or out with the
+	// `kujo.sphc.io/suffix` annotation.
+	SuffixConfig bool
+
+	// NameReferences are the fields which refer to Jobs by name, next to the
+	// DefaultNameReferences. When a Job is renamed, these fields are updated
+	// in all resources of the input.
+	NameReferences []NameReference
+
+	// InjectEnv adds the KUJO_HASH, KUJO_JOB_NAME and KUJO_BASE_NAME
+	// environment variables to all containers of renamed jobs. The variables
+	// don't change the hash of a job.
+	InjectEnv bool
+
+	// WorkloadKinds are the kinds of resources which are renamed next to the
+	// DefaultWorkloadKinds, like Argo Workflows or Tekton TaskRuns. See
+	// ReadWorkloadKinds.
+	WorkloadKinds []WorkloadKind
+}
+
+// namespace resolves the namespace of an object. Objects without a namespace
+// are placed in the configured namespace, or in `default` when no namespace is
+// configured.
+func (o Options) namespace(ns string) string {
+	if ns != "" {
+		return ns
+	}
+
+	if o.Namespace != "" {
+		return o.Namespace
+	}
+
+	return "default"
+}
@@ -0,0 +1,32 @@
+package kujo
+
+import "testing"
+
+func TestOptionsNamespace(t *testing.T) {
+	tcs := map[string]struct {
+		opts      Options
+		namespace string
+		resolved  string
+	}{
+		"without a namespace": {
+			resolved: "default",
+		},
+		"with a namespace on the object": {
+			opts:      Options{Namespace: "migrations"},
+			namespace: "jobs",
+			resolved:  "jobs",
+		},
+		"with a configured namespace": {
+			opts:     Options{Namespace: "migrations"},
+			resolved: "migrations",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			if ns := tc.opts.namespace(tc.namespace); ns != tc.resolved {
+				t.Errorf("Expected namespace '%s', got '%s'", tc.resolved, ns)
+			}
+		})
+	}
+}
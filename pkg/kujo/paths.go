@@ -0,0 +1,162 @@
+package kujo
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// hashPathsAnnKey is the annotation which lists the glob patterns of the files
+// which are folded into the hash of a job.
+const hashPathsAnnKey = annKey + "/hash-paths"
+
+// pathInputs returns the digests of all files which match the given glob
+// patterns, relative to the base directory. Next to the patterns supported by
+// path.Match, a `**` segment matches any number of directories. A pattern
+// which matches a directory includes all files within it. Files are returned
+// in lexical order, each file only once.
+func pathInputs(patterns []string, baseDir string) ([]HashInput, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	if baseDir == "" {
+		baseDir = "."
+	}
+
+	files := map[string]string{}
+	for _, pattern := range patterns {
+		matches, err := globFiles(pattern, baseDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match the pattern '%s' in '%s'", pattern, baseDir)
+		}
+
+		for name, file := range matches {
+			files[name] = file
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	inputs := make([]HashInput, 0, len(names))
+	for _, name := range names {
+		data, err := ioutil.ReadFile(files[name])
+		if err != nil {
+			return nil, err
+		}
+
+		// the name is part of the digest, so renaming a file changes the hash
+		digest := sha256.New()
+		fmt.Fprintf(digest, "%s\n", name)
+		digest.Write(data)
+
+		inputs = append(inputs, HashInput{Key: "File/" + name, Digest: fmt.Sprintf("%x", digest.Sum(nil))})
+	}
+
+	return inputs, nil
+}
+
+// globFiles returns the files matching the pattern by their slash separated
+// path relative to the base directory.
+func globFiles(pattern, baseDir string) (map[string]string, error) {
+	pattern = path.Clean(filepath.ToSlash(pattern))
+	if path.IsAbs(pattern) {
+		return nil, fmt.Errorf("the pattern '%s' has to be relative to the base directory", pattern)
+	}
+
+	if pattern == ".." || strings.HasPrefix(pattern, "../") {
+		return nil, fmt.Errorf("the pattern '%s' has to stay within the base directory", pattern)
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("the pattern '%s' is invalid: %s", pattern, err)
+	}
+
+	segments := strings.Split(pattern, "/")
+
+	// only walk the part of the tree which can match
+	var prefix []string
+	for _, segment := range segments {
+		if isGlob(segment) || segment == "**" {
+			break
+		}
+		prefix = append(prefix, segment)
+	}
+
+	root := filepath.Join(baseDir, filepath.FromSlash(strings.Join(prefix, "/")))
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	files := map[string]string{}
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(baseDir, file)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		parts := strings.Split(name, "/")
+		for i := len(parts); i > 0; i-- {
+			if matchSegments(segments, parts[:i]) {
+				files[name] = file
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+// matchSegments reports if the path segments match the pattern segments, where
+// a `**` segment matches any number of path segments.
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(name) == 0 {
+		return false
+	}
+
+	if ok, _ := path.Match(pattern[0], name[0]); !ok {
+		return false
+	}
+
+	return matchSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,146 @@
+package kujo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMatchSegments(t *testing.T) {
+	tcs := map[string]struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		"with an exact match":               {"migrations/001.sql", "migrations/001.sql", true},
+		"with a wildcard":                   {"migrations/*.sql", "migrations/001.sql", true},
+		"with a wildcard in a subdirectory": {"migrations/*.sql", "migrations/nested/002.sql", false},
+		"with a double star":                {"migrations/**/*.sql", "migrations/nested/002.sql", true},
+		"with a double star matching none":  {"migrations/**/*.sql", "migrations/001.sql", true},
+		"with a leading double star":        {"**/*.sql", "db/migrations/001.sql", true},
+		"with a trailing double star":       {"migrations/**", "migrations/nested/002.sql", true},
+		"with a different extension":        {"migrations/**/*.sql", "migrations/README.md", false},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			match := matchSegments(strings.Split(tc.pattern, "/"), strings.Split(tc.name, "/"))
+			if match != tc.match {
+				t.Errorf("Expected match to be %t, got %t", tc.match, match)
+			}
+		})
+	}
+}
+
+func TestPathInputs(t *testing.T) {
+	tcs := map[string]struct {
+		patterns []string
+		keys     []string
+		err      bool
+	}{
+		"without patterns": {},
+		"with a double star pattern": {
+			patterns: []string{"migrations/**/*.sql"},
+			keys:     []string{"File/migrations/001_users.sql", "File/migrations/nested/002_name.sql"},
+		},
+		"with a directory": {
+			patterns: []string{"migrations/nested"},
+			keys:     []string{"File/migrations/nested/002_name.sql"},
+		},
+		"with overlapping patterns": {
+			patterns: []string{"migrations/*", "**/*.sql"},
+			keys:     []string{"File/migrations/001_users.sql", "File/migrations/README.md", "File/migrations/nested/002_name.sql"},
+		},
+		"with a pattern without matches": {
+			patterns: []string{"migrations/*.rb"},
+			err:      true,
+		},
+		"with an invalid pattern": {
+			patterns: []string{"migrations/[.sql"},
+			err:      true,
+		},
+		"with a pattern which is cleaned": {
+			patterns: []string{"migrations/nested/../*.sql"},
+			keys:     []string{"File/migrations/001_users.sql"},
+		},
+		"with an absolute pattern": {
+			patterns: []string{"/etc/passwd"},
+			err:      true,
+		},
+		"with a pattern outside of the base directory": {
+			patterns: []string{"../paths/migrations/*.sql"},
+			err:      true,
+		},
+		"with a pattern which leaves the base directory": {
+			patterns: []string{"migrations/../../paths/migrations/*.sql"},
+			err:      true,
+		},
+		"with the parent directory": {
+			patterns: []string{".."},
+			err:      true,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			inputs, err := pathInputs(tc.patterns, "testdata/paths")
+			if tc.err != (err != nil) {
+				t.Errorf("Expected error to be %t, got '%v'", tc.err, err)
+			}
+
+			// we've got an error, don't run further tests
+			if err != nil {
+				return
+			}
+
+			var keys []string
+			for _, input := range inputs {
+				keys = append(keys, input.Key)
+			}
+
+			if !cmp.Equal(tc.keys, keys) {
+				t.Errorf("Expected keys to match, got diff %s", cmp.Diff(tc.keys, keys))
+			}
+		})
+	}
+}
+
+func TestHashJobsPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kujo")
+	if err != nil {
+		t.Fatalf("Expected no error creating a directory, got '%s'", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "001.sql"), []byte("CREATE TABLE users (id INT);"), 0644); err != nil {
+		t.Fatalf("Expected no error writing a file, got '%s'", err)
+	}
+
+	job := unstructured.Unstructured{Object: map[string]interface{}{}}
+	job.SetName("foo")
+	job.SetAnnotations(map[string]string{"kujo.sphc.io/hash-paths": "*.sql"})
+
+	opts := Options{BaseDir: dir}
+	original, err := hashJobs([]unstructured.Unstructured{job}, nil, opts)
+	if err != nil {
+		t.Fatalf("Expected no error hashing the jobs, got '%s'", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "001.sql"), []byte("CREATE TABLE accounts (id INT);"), 0644); err != nil {
+		t.Fatalf("Expected no error writing a file, got '%s'", err)
+	}
+
+	changed, err := hashJobs([]unstructured.Unstructured{job}, nil, opts)
+	if err != nil {
+		t.Fatalf("Expected no error hashing the jobs, got '%s'", err)
+	}
+
+	if original["default/foo"].Full == changed["default/foo"].Full {
+		t.Errorf("Expected the hash to change when a file changes, got '%s' for both", original["default/foo"].Short)
+	}
+}
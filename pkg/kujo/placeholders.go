@@ -0,0 +1,125 @@
+package kujo
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// placeholderPattern matches the placeholders for the unique name and hash of
+// a job, like `$(kujo:migrate.name)`, `$(kujo:migrations/migrate.hash)` or
+// `$(kujo:Pod/migrations/migrate.name)`.
+var placeholderPattern = regexp.MustCompile(`\$\(kujo:([^()\s]+)\.(name|hash)\)`)
+
+// substitutePlaceholders replaces the placeholders in all string values of the
+// resources with the unique name or short hash of the renamed job. Jobs are
+// referenced by their original name, in the namespace of the resource, or as
+// `namespace/name`, and can be prefixed with their kind as `Kind/namespace/name`.
+// The jobs are hashed before the placeholders are replaced, so a placeholder is
+// part of the hash as it's written. A placeholder which matches more than one
+// job is an error.
+func substitutePlaceholders(resourceList []unstructured.Unstructured, renamed []renamedJob, opts Options) error {
+	jobs := map[string][]renamedJob{}
+	for _, job := range renamed {
+		key := fmt.Sprintf("%s/%s", job.namespace, job.original)
+		jobs[key] = append(jobs[key], job)
+	}
+
+	for _, rs := range resourceList {
+		ns := opts.namespace(rs.GetNamespace())
+		unresolved := map[string]bool{}
+		ambiguous := map[string]bool{}
+		substituteValue(rs.Object, func(ref, field string) (string, bool) {
+			var kind string
+			key, lookup := ref, ref
+			switch strings.Count(ref, "/") {
+			case 0:
+				key = fmt.Sprintf("%s/%s", ns, ref)
+				lookup = key
+			case 2:
+				parts := strings.SplitN(ref, "/", 2)
+				kind, lookup = parts[0], parts[1]
+			}
+
+			var matches []renamedJob
+			for _, job := range jobs[lookup] {
+				if kind == "" || job.kind.Kind == kind {
+					matches = append(matches, job)
+				}
+			}
+
+			if len(matches) == 0 {
+				unresolved[key] = true
+				return "", false
+			}
+
+			if len(matches) > 1 {
+				ambiguous[key] = true
+				return "", false
+			}
+
+			job := matches[0]
+
+			if field == "hash" {
+				return job.hash.Short, true
+			}
+			return job.name, true
+		})
+
+		if len(ambiguous) > 0 {
+			return fmt.Errorf("%s '%s' has placeholders which match more than one job: %s", rs.GetKind(), rs.GetName(), joinedKeys(ambiguous))
+		}
+
+		if len(unresolved) == 0 {
+			continue
+		}
+
+		err := fmt.Errorf("%s '%s' has placeholders for jobs which are not part of the input: %s", rs.GetKind(), rs.GetName(), joinedKeys(unresolved))
+		if opts.StrictRefs {
+			return err
+		}
+		log.Printf("%s", err)
+	}
+
+	return nil
+}
+
+// joinedKeys returns the sorted keys of the set, separated by commas.
+func joinedKeys(set map[string]bool) string {
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return strings.Join(keys, ", ")
+}
+
+// substituteValue replaces the placeholders in all strings within the value.
+// Placeholders which can't be resolved are left as they are.
+func substituteValue(value interface{}, resolve func(ref, field string) (string, bool)) interface{} {
+	switch v := value.(type) {
+	case string:
+		return placeholderPattern.ReplaceAllStringFunc(v, func(placeholder string) string {
+			match := placeholderPattern.FindStringSubmatch(placeholder)
+			if resolved, ok := resolve(match[1], match[2]); ok {
+				return resolved
+			}
+			return placeholder
+		})
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = substituteValue(item, resolve)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = substituteValue(item, resolve)
+		}
+	}
+
+	return value
+}
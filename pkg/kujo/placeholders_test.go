@@ -0,0 +1,209 @@
+package kujo
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestSubstitutePlaceholders(t *testing.T) {
+	input, err := ioutil.ReadFile("testdata/placeholders-input.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error reading the input file, got '%s'", err)
+	}
+
+	output, err := Convert(bytes.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Expected no error converting the input, got '%s'", err)
+	}
+
+	resources, err := ResourcesFromReader(bytes.NewReader(output))
+	if err != nil {
+		t.Fatalf("Expected no error reading the output, got '%s'", err)
+	}
+
+	tcs := map[string]struct {
+		index    int
+		path     []string
+		expected string
+	}{
+		"with the job itself": {
+			index:    0,
+			path:     []string{"spec", "template", "spec", "containers", "0", "env", "0", "value"},
+			expected: "migrate-5m75c6m825",
+		},
+		"with another job": {
+			index:    1,
+			path:     []string{"spec", "template", "spec", "containers", "0", "command", "2"},
+			expected: "kubectl wait --for=condition=complete job/migrate-5m75c6m825 && seed",
+		},
+		"with a namespace and hash": {
+			index:    2,
+			path:     []string{"data", "watch"},
+			expected: "migrate-5m75c6m825 5m75c6m825",
+		},
+		"with a job in another namespace": {
+			index:    2,
+			path:     []string{"data", "missing"},
+			expected: "$(kujo:migrate.name)",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			value, ok := nestedValue(resources[tc.index], tc.path)
+			if !ok {
+				t.Fatalf("Expected a value at %v", tc.path)
+			}
+
+			if value != tc.expected {
+				t.Errorf("Expected value to be '%s', got '%s'", tc.expected, value)
+			}
+		})
+	}
+
+	t.Run("with strict references", func(t *testing.T) {
+		if _, err := Convert(bytes.NewReader(input), Options{StrictRefs: true}); err == nil {
+			t.Errorf("Expected an error for the unresolved placeholder")
+		}
+	})
+}
+
+func TestAmbiguousPlaceholders(t *testing.T) {
+	job := `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  namespace: migrations
+  annotations:
+    kujo.sphc.io: "true"
+spec:
+  template:
+    spec:
+      containers:
+      - name: migrate
+        image: %s
+      restartPolicy: Never
+`
+	configMap := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: deploy
+  namespace: migrations
+data:
+  watch: $(kujo:migrate.name)
+`
+	input := strings.Join([]string{fmt.Sprintf(job, "migrate:v1"), fmt.Sprintf(job, "migrate:v2"), configMap}, "---\n")
+
+	_, err := Convert(strings.NewReader(input), Options{})
+	if err == nil {
+		t.Fatalf("Expected an error for the ambiguous placeholder")
+	}
+
+	if !strings.Contains(err.Error(), "more than one job: migrations/migrate") {
+		t.Errorf("Expected the error to name the job, got '%s'", err)
+	}
+}
+
+func TestKindPlaceholders(t *testing.T) {
+	resources := `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  namespace: migrations
+  annotations:
+    kujo.sphc.io: "true"
+spec:
+  template:
+    spec:
+      containers:
+      - name: migrate
+        image: migrate
+      restartPolicy: Never
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: migrate
+  namespace: migrations
+  annotations:
+    kujo.sphc.io: "true"
+spec:
+  containers:
+  - name: migrate
+    image: migrate
+  restartPolicy: Never
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: deploy
+  namespace: migrations
+data:
+  job: %s
+  pod: $(kujo:Pod/migrations/migrate.name)
+`
+
+	t.Run("without a kind", func(t *testing.T) {
+		_, err := Convert(strings.NewReader(fmt.Sprintf(resources, "$(kujo:migrate.name)")), Options{})
+		if err == nil {
+			t.Errorf("Expected an error for the placeholder which matches the Job and the Pod")
+		}
+	})
+
+	t.Run("with a kind", func(t *testing.T) {
+		output, err := Convert(strings.NewReader(fmt.Sprintf(resources, "$(kujo:Job/migrations/migrate.name)")), Options{})
+		if err != nil {
+			t.Fatalf("Expected no error converting the input, got '%s'", err)
+		}
+
+		resources, err := ResourcesFromReader(bytes.NewReader(output))
+		if err != nil {
+			t.Fatalf("Expected no error reading the output, got '%s'", err)
+		}
+
+		data := resources[2].Object["data"].(map[string]interface{})
+		if data["job"] != resources[0].GetName() {
+			t.Errorf("Expected the Job placeholder to be '%s', got '%s'", resources[0].GetName(), data["job"])
+		}
+
+		if data["pod"] != resources[1].GetName() {
+			t.Errorf("Expected the Pod placeholder to be '%s', got '%s'", resources[1].GetName(), data["pod"])
+		}
+
+		if resources[0].GetName() == resources[1].GetName() {
+			t.Errorf("Expected the Job and the Pod to get different names")
+		}
+	})
+}
+
+func TestPlaceholdersDontChangeHash(t *testing.T) {
+	input, err := ioutil.ReadFile("testdata/placeholders-input.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error reading the input file, got '%s'", err)
+	}
+
+	before, err := Explain(bytes.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Expected no error explaining the input, got '%s'", err)
+	}
+
+	output, err := Convert(bytes.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Expected no error converting the input, got '%s'", err)
+	}
+
+	resources, err := ResourcesFromReader(bytes.NewReader(output))
+	if err != nil {
+		t.Fatalf("Expected no error reading the output, got '%s'", err)
+	}
+
+	for i, explanation := range before {
+		hash := resources[i].GetAnnotations()[hashAnnKey]
+		if hash != explanation.Hash {
+			t.Errorf("Expected the hash of job '%s' to be '%s', got '%s'", explanation.Name, explanation.Hash, hash)
+		}
+	}
+}
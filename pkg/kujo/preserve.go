@@ -0,0 +1,622 @@
+package kujo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// yamlDocument is a single document from the input as it was read. It keeps
+// the raw data and the YAML node tree so changes to the object can be written
+// back without reformatting the rest of the document.
+type yamlDocument struct {
+	raw      []byte
+	node     *yaml.Node
+	object   unstructured.Unstructured
+	original map[string]interface{}
+}
+
+// splice replaces the data between start and end with value.
+type splice struct {
+	start, end int
+	value      string
+}
+
+// documentsFromSource splits the data of the reader into its YAML documents
+// and parses both the object and the YAML node tree of each document.
+func documentsFromSource(rdr io.Reader) ([]*yamlDocument, error) {
+	src, err := ioutil.ReadAll(rdr)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []*yamlDocument
+	for _, raw := range splitDocuments(src) {
+		objects, err := documentsFromReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+
+		doc := &yamlDocument{raw: raw}
+		switch len(objects) {
+		case 0:
+		case 1:
+			var node yaml.Node
+			if err := yaml.Unmarshal(raw, &node); err != nil {
+				return nil, err
+			}
+
+			if node.Kind == yaml.DocumentNode && len(node.Content) == 1 {
+				doc.node = &node
+				doc.object = objects[0]
+				doc.original = runtime.DeepCopyJSON(objects[0].Object)
+			}
+		default:
+			return nil, fmt.Errorf("can not preserve the formatting of multiple objects in a single document")
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// splitDocuments splits the data into YAML documents. Each document holds the
+// separator it starts with, so joining the documents results in the original
+// data.
+func splitDocuments(src []byte) [][]byte {
+	var docs [][]byte
+	start, offset := 0, 0
+	for offset < len(src) {
+		end := bytes.IndexByte(src[offset:], '\n')
+		if end == -1 {
+			end = len(src)
+		} else {
+			end += offset + 1
+		}
+
+		line := strings.TrimRightFunc(string(src[offset:end]), func(r rune) bool {
+			return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+		})
+		if line == "---" && offset > start {
+			docs = append(docs, src[start:offset])
+			start = offset
+		}
+
+		offset = end
+	}
+
+	if start < len(src) {
+		docs = append(docs, src[start:])
+	}
+
+	return docs
+}
+
+// marshalDocuments outputs the documents in their original format. Documents
+// which haven't changed are output as is, changed values are replaced in place
+// where possible. If the structure of a document changed, the document is
+// encoded from its YAML node tree which keeps comments and the order of keys.
+func marshalDocuments(docs []*yamlDocument) ([]byte, error) {
+	buf := bytes.NewBuffer([]byte{})
+	for _, doc := range docs {
+		out, err := doc.marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := buf.Write(out); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (d *yamlDocument) marshal() ([]byte, error) {
+	if d.node == nil || reflect.DeepEqual(d.original, d.object.Object) {
+		return d.raw, nil
+	}
+
+	var splices []splice
+	structural, err := patchNode(d.raw, d.node.Content[0], d.original, d.object.Object, &splices)
+	if err != nil {
+		return nil, err
+	}
+
+	if !structural {
+		return applySplices(d.raw, splices), nil
+	}
+
+	header := documentHeader(d.raw)
+	buf := bytes.NewBuffer(append([]byte{}, d.raw[:header]...))
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(d.node); err != nil {
+		return nil, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// patchNode updates the node, which represents the value from, so it
+// represents the value to. Scalar values which can be replaced in the source
+// data directly are added to the list of splices. The returned boolean
+// indicates if the node had to be changed in a way which can't be represented
+// by splices.
+func patchNode(src []byte, node *yaml.Node, from, to interface{}, splices *[]splice) (bool, error) {
+	if reflect.DeepEqual(from, to) {
+		return false, nil
+	}
+
+	if node.Kind == yaml.AliasNode {
+		return false, fmt.Errorf("can not preserve the formatting of the alias on line %d", node.Line)
+	}
+
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap && node.Kind == yaml.MappingNode {
+		return patchMappingNode(src, node, fromMap, toMap, splices)
+	}
+
+	fromSlice, fromIsSlice := from.([]interface{})
+	toSlice, toIsSlice := to.([]interface{})
+	if fromIsSlice && toIsSlice && node.Kind == yaml.SequenceNode && len(fromSlice) <= len(toSlice) && len(fromSlice) == len(node.Content) {
+		var structural bool
+		for i := range fromSlice {
+			s, err := patchNode(src, node.Content[i], fromSlice[i], toSlice[i], splices)
+			if err != nil {
+				return false, err
+			}
+			structural = structural || s
+		}
+
+		if added := toSlice[len(fromSlice):]; len(added) > 0 {
+			sp, ok, err := appendSplice(src, node, added)
+			if err != nil {
+				return false, err
+			}
+
+			if ok {
+				*splices = append(*splices, sp)
+			}
+			structural = structural || !ok
+
+			for _, item := range added {
+				var itemNode yaml.Node
+				if err := itemNode.Encode(item); err != nil {
+					return false, err
+				}
+				node.Content = append(node.Content, &itemNode)
+			}
+		}
+
+		return structural, nil
+	}
+
+	toStr, toIsStr := to.(string)
+	if _, fromIsStr := from.(string); fromIsStr && toIsStr && node.Kind == yaml.ScalarNode {
+		if sp, ok := scalarSplice(src, node, toStr); ok {
+			node.Value = toStr
+			*splices = append(*splices, sp)
+			return false, nil
+		}
+	}
+
+	var replacement yaml.Node
+	if err := replacement.Encode(to); err != nil {
+		return false, err
+	}
+
+	if node.Kind == yaml.ScalarNode && replacement.Kind == yaml.ScalarNode && toIsStr {
+		replacement.Style = node.Style
+	}
+	replacement.HeadComment = node.HeadComment
+	replacement.LineComment = node.LineComment
+	replacement.FootComment = node.FootComment
+	*node = replacement
+
+	return true, nil
+}
+
+func patchMappingNode(src []byte, node *yaml.Node, from, to map[string]interface{}, splices *[]splice) (bool, error) {
+	var structural bool
+	content := make([]*yaml.Node, 0, len(node.Content))
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		seen[key.Value] = true
+
+		toValue, ok := to[key.Value]
+		if !ok {
+			structural = true
+			continue
+		}
+
+		s, err := patchNode(src, value, from[key.Value], toValue, splices)
+		if err != nil {
+			return false, err
+		}
+		structural = structural || s
+		content = append(content, key, value)
+	}
+
+	var added []string
+	for _, key := range sortedKeys(to) {
+		if seen[key] {
+			continue
+		}
+
+		var keyNode, valueNode yaml.Node
+		if err := keyNode.Encode(key); err != nil {
+			return false, err
+		}
+
+		if err := valueNode.Encode(to[key]); err != nil {
+			return false, err
+		}
+
+		added = append(added, key)
+		content = append(content, &keyNode, &valueNode)
+	}
+
+	if len(added) > 0 {
+		sp, ok, err := insertSplice(src, node, added, to)
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
+			*splices = append(*splices, sp)
+		}
+		structural = structural || !ok
+	}
+
+	node.Content = content
+	return structural, nil
+}
+
+// insertSplice returns the splice which inserts the given keys in front of the
+// first key of the mapping node. Keys are written in the style of the mapping,
+// flow mappings get their keys and values as JSON so JSON documents stay valid.
+func insertSplice(src []byte, node *yaml.Node, keys []string, values map[string]interface{}) (splice, bool, error) {
+	if len(node.Content) == 0 {
+		return splice{}, false, nil
+	}
+
+	first := node.Content[0]
+	start, ok := nodeOffset(src, first)
+	if !ok || first.Kind != yaml.ScalarNode {
+		return splice{}, false, nil
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if node.Style&yaml.FlowStyle != 0 {
+		for _, key := range keys {
+			k, err := json.Marshal(key)
+			if err != nil {
+				return splice{}, false, err
+			}
+
+			v, err := json.Marshal(values[key])
+			if err != nil {
+				return splice{}, false, err
+			}
+
+			fmt.Fprintf(buf, "%s: %s, ", k, v)
+		}
+
+		return splice{start: start, end: start, value: buf.String()}, true, nil
+	}
+
+	// the first key has to start its line, or follow the indicator of a
+	// sequence item, for the inserted keys to get the same indentation
+	lineStart := bytes.LastIndexByte(src[:start], '\n') + 1
+	if strings.Trim(string(src[lineStart:start]), " -") != "" {
+		return splice{}, false, nil
+	}
+	indent := strings.Repeat(" ", first.Column-1)
+
+	added := map[string]interface{}{}
+	for _, key := range keys {
+		added[key] = values[key]
+	}
+
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(added); err != nil {
+		return splice{}, false, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return splice{}, false, err
+	}
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	for i := 1; i < len(lines); i++ {
+		lines[i] = indent + lines[i]
+	}
+
+	return splice{start: start, end: start, value: strings.Join(lines, "")}, true, nil
+}
+
+// appendSplice returns the splice which appends the given items to the end of
+// the sequence node. Items are written in the style of the sequence, flow
+// sequences get their items as JSON so JSON documents stay valid.
+func appendSplice(src []byte, node *yaml.Node, items []interface{}) (splice, bool, error) {
+	if len(node.Content) == 0 {
+		return splice{}, false, nil
+	}
+
+	start, ok := nodeOffset(src, node)
+	if !ok {
+		return splice{}, false, nil
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if node.Style&yaml.FlowStyle != 0 {
+		end, ok := flowEnd(src, start)
+		if !ok {
+			return splice{}, false, nil
+		}
+
+		for _, item := range items {
+			v, err := json.Marshal(item)
+			if err != nil {
+				return splice{}, false, err
+			}
+
+			fmt.Fprintf(buf, ", %s", v)
+		}
+
+		// append the items right after the last item, in front of any space
+		// before the closing bracket
+		end = len(bytes.TrimRight(src[:end], " \t\r\n"))
+		return splice{start: end, end: end, value: buf.String()}, true, nil
+	}
+
+	if src[start] != '-' {
+		return splice{}, false, nil
+	}
+
+	// the last item ends with the last line which is indented further than
+	// the sequence indicators, blank lines and comments after it are kept
+	// after the appended items
+	offset, ok := nodeOffset(src, node.Content[len(node.Content)-1])
+	if !ok {
+		return splice{}, false, nil
+	}
+
+	end := lineEnd(src, offset)
+	for scan := end; scan < len(src); {
+		next := lineEnd(src, scan)
+		line := strings.TrimRight(string(src[scan:next]), "\r\n")
+		content := strings.TrimLeft(line, " ")
+		if content != "" && !strings.HasPrefix(content, "#") {
+			if len(line)-len(content) < node.Column {
+				break
+			}
+			end = next
+		}
+		scan = next
+	}
+
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(items); err != nil {
+		return splice{}, false, err
+	}
+
+	if err := enc.Close(); err != nil {
+		return splice{}, false, err
+	}
+
+	indent := strings.Repeat(" ", node.Column-1)
+	lines := strings.SplitAfter(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i := range lines {
+		lines[i] = indent + lines[i]
+	}
+
+	value := strings.Join(lines, "") + "\n"
+	if end > 0 && src[end-1] != '\n' {
+		value = "\n" + strings.TrimSuffix(value, "\n")
+	}
+
+	return splice{start: end, end: end, value: value}, true, nil
+}
+
+// lineEnd returns the offset of the start of the line after the offset.
+func lineEnd(src []byte, offset int) int {
+	if i := bytes.IndexByte(src[offset:], '\n'); i != -1 {
+		return offset + i + 1
+	}
+
+	return len(src)
+}
+
+// flowEnd returns the offset of the bracket which closes the flow collection
+// starting at the offset. Quoted strings are skipped.
+func flowEnd(src []byte, start int) (int, bool) {
+	depth := 0
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				return i, true
+			}
+		case '"':
+			if quoteStart(src, i) {
+				for i++; i < len(src) && src[i] != '"'; i++ {
+					if src[i] == '\\' {
+						i++
+					}
+				}
+			}
+		case '\'':
+			// a quote is escaped by doubling it
+			if quoteStart(src, i) {
+				for i++; i < len(src); i++ {
+					if src[i] == '\'' {
+						if i+1 < len(src) && src[i+1] == '\'' {
+							i++
+							continue
+						}
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// quoteStart reports if the quote at the offset starts a quoted scalar in a
+// flow collection, instead of being part of a plain scalar like `it's`.
+func quoteStart(src []byte, offset int) bool {
+	prev := bytes.TrimRight(src[:offset], " \t\r\n")
+	return len(prev) > 0 && strings.IndexByte("[{,:", prev[len(prev)-1]) != -1
+}
+
+// scalarSplice returns the splice which replaces the value of a single line
+// scalar node in the source data. It only returns a splice when the new value
+// can be written in the same style as the original value.
+func scalarSplice(src []byte, node *yaml.Node, value string) (splice, bool) {
+	start, ok := nodeOffset(src, node)
+	if !ok || strings.ContainsAny(node.Value, "\n") {
+		return splice{}, false
+	}
+
+	var quote string
+	switch node.Style {
+	case 0:
+		var resolved interface{}
+		if !isPlainSafe(value) || yaml.Unmarshal([]byte(value), &resolved) != nil || resolved != value {
+			return splice{}, false
+		}
+	case yaml.DoubleQuotedStyle:
+		quote = `"`
+		if strings.ContainsAny(value, "\"\\") || strings.ContainsAny(node.Value, "\"\\") {
+			return splice{}, false
+		}
+	case yaml.SingleQuotedStyle:
+		quote = "'"
+		if strings.ContainsAny(value, "'") || strings.ContainsAny(node.Value, "'") {
+			return splice{}, false
+		}
+	default:
+		return splice{}, false
+	}
+
+	end := start + len(quote)*2 + len(node.Value)
+	if end > len(src) || string(src[start:end]) != quote+node.Value+quote {
+		return splice{}, false
+	}
+
+	return splice{start: start, end: end, value: quote + value + quote}, true
+}
+
+// isPlainSafe reports if the value can be written as a plain scalar without
+// changing its meaning.
+func isPlainSafe(value string) bool {
+	if value == "" {
+		return false
+	}
+
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '.' || r == '_' || r == '/':
+		default:
+			return false
+		}
+	}
+
+	return value[0] != '-'
+}
+
+// nodeOffset converts the line and column of the node to a byte offset in the
+// source data.
+func nodeOffset(src []byte, node *yaml.Node) (int, bool) {
+	offset := 0
+	for line := 1; line < node.Line; line++ {
+		i := bytes.IndexByte(src[offset:], '\n')
+		if i == -1 {
+			return 0, false
+		}
+		offset += i + 1
+	}
+
+	for col := 1; col < node.Column; col++ {
+		if offset >= len(src) || src[offset] == '\n' {
+			return 0, false
+		}
+		_, size := utf8.DecodeRune(src[offset:])
+		offset += size
+	}
+
+	return offset, true
+}
+
+func applySplices(src []byte, splices []splice) []byte {
+	buf := bytes.NewBuffer([]byte{})
+	offset := 0
+	for _, sp := range sortedSplices(splices) {
+		buf.Write(src[offset:sp.start])
+		buf.WriteString(sp.value)
+		offset = sp.end
+	}
+	buf.Write(src[offset:])
+
+	return buf.Bytes()
+}
+
+// documentHeader returns the length of the separator line the document starts
+// with.
+func documentHeader(raw []byte) int {
+	if !bytes.HasPrefix(raw, []byte("---")) {
+		return 0
+	}
+
+	if i := bytes.IndexByte(raw, '\n'); i != -1 {
+		return i + 1
+	}
+
+	return len(raw)
+}
+
+func sortedSplices(splices []splice) []splice {
+	sorted := append([]splice{}, splices...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].start < sorted[j].start
+	})
+
+	return sorted
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
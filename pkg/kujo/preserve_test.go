@@ -0,0 +1,201 @@
+package kujo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitDocuments(t *testing.T) {
+	tcs := map[string]struct {
+		input string
+		docs  int
+	}{
+		"without data": {
+			input: "",
+		},
+		"with a single document": {
+			input: "a: b\n",
+			docs:  1,
+		},
+		"with a leading separator": {
+			input: "---\na: b\n---\nc: d",
+			docs:  2,
+		},
+		"with comments before the first separator": {
+			input: "# header\n---\na: b\n--- \nc: d\n",
+			docs:  3,
+		},
+		"with separators in a block scalar": {
+			input: "a: |\n  ---\n  b\n",
+			docs:  1,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			docs := splitDocuments([]byte(tc.input))
+			if len(docs) != tc.docs {
+				t.Errorf("Expected %d documents, got %d", tc.docs, len(docs))
+			}
+
+			var joined string
+			for _, doc := range docs {
+				joined += string(doc)
+			}
+
+			if joined != tc.input {
+				t.Errorf("Expected documents to join to\n%s\ngot\n%s", tc.input, joined)
+			}
+		})
+	}
+}
+
+func TestMarshalDocuments(t *testing.T) {
+	input := `---
+# the config
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "config" # quoted
+data:
+  b: "1"
+  a: '2'
+`
+
+	tcs := map[string]struct {
+		update func(docs []*yamlDocument)
+		output string
+	}{
+		"without changes": {
+			update: func(docs []*yamlDocument) {},
+			output: input,
+		},
+		"with a changed scalar": {
+			update: func(docs []*yamlDocument) {
+				docs[0].object.SetName("config-abc")
+			},
+			output: strings.Replace(input, `"config"`, `"config-abc"`, 1),
+		},
+		"with an added value": {
+			update: func(docs []*yamlDocument) {
+				docs[0].object.SetLabels(map[string]string{"app": "kujo"})
+			},
+			output: `---
+# the config
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  labels:
+    app: kujo
+  name: "config" # quoted
+data:
+  b: "1"
+  a: '2'
+`,
+		},
+		"with an added mapping": {
+			update: func(docs []*yamlDocument) {
+				docs[0].object.Object["spec"] = map[string]interface{}{"size": "1Gi"}
+			},
+			output: `---
+# the config
+spec:
+  size: 1Gi
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "config" # quoted
+data:
+  b: "1"
+  a: '2'
+`,
+		},
+		"with a removed value": {
+			update: func(docs []*yamlDocument) {
+				delete(docs[0].object.Object["data"].(map[string]interface{}), "b")
+			},
+			output: `---
+# the config
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "config" # quoted
+data:
+  a: '2'
+`,
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			docs, err := documentsFromSource(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("Expected no error reading the documents, got '%s'", err)
+			}
+
+			tc.update(docs)
+
+			output, err := marshalDocuments(docs)
+			if err != nil {
+				t.Fatalf("Expected no error marshalling the documents, got '%s'", err)
+			}
+
+			if string(output) != tc.output {
+				t.Errorf("Expected output\n%s\ngot\n%s", tc.output, string(output))
+			}
+		})
+	}
+}
+
+func TestMarshalDocumentsAppendedItems(t *testing.T) {
+	tcs := map[string]struct {
+		input  string
+		output string
+	}{
+		"with a block sequence": {
+			input:  "kind: Test\nlist:\n- a: 1\n  b: 2\nother: x\n",
+			output: "kind: Test\nlist:\n- a: 1\n  b: 2\n- c: 3\nother: x\n",
+		},
+		"with an indented block sequence": {
+			input:  "kind: Test\nlist:\n  - a: 1 # one\n\n  # trailing\nother: x\n",
+			output: "kind: Test\nlist:\n  - a: 1 # one\n  - c: 3\n\n  # trailing\nother: x\n",
+		},
+		"with a block scalar": {
+			input:  "kind: Test\nlist:\n- a: |\n    1\n    2\n",
+			output: "kind: Test\nlist:\n- a: |\n    1\n    2\n- c: 3\n",
+		},
+		"without a trailing newline": {
+			input:  "kind: Test\nlist:\n- a: 1",
+			output: "kind: Test\nlist:\n- a: 1\n- c: 3",
+		},
+		"with a flow sequence": {
+			input:  "kind: Test\nlist: [{a: 1}, {b: 'it''s]'} ] # flow\n",
+			output: "kind: Test\nlist: [{a: 1}, {b: 'it''s]'}, {\"c\":3} ] # flow\n",
+		},
+		"with a JSON document": {
+			input:  "{\"kind\": \"Test\", \"list\": [{\"a\": 1}]}\n",
+			output: "{\"kind\": \"Test\", \"list\": [{\"a\": 1}, {\"c\":3}]}\n",
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			docs, err := documentsFromSource(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatalf("Expected no error reading the documents, got '%s'", err)
+			}
+
+			list := docs[0].object.Object["list"].([]interface{})
+			docs[0].object.Object["list"] = append(list, map[string]interface{}{"c": int64(3)})
+
+			output, err := marshalDocuments(docs)
+			if err != nil {
+				t.Fatalf("Expected no error marshalling the documents, got '%s'", err)
+			}
+
+			if string(output) != tc.output {
+				t.Errorf("Expected output\n%s\ngot\n%s", tc.output, string(output))
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package kujo
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// originalNameAnnKey is the annotation which holds the name of the job
+	// before it was renamed.
+	originalNameAnnKey = annKey + "/original-name"
+
+	// hashAnnKey is the annotation which holds the full hash of the job.
+	hashAnnKey = annKey + "/hash"
+
+	// inputsAnnKey is the annotation which holds the keys of the ConfigMaps
+	// and Secrets which contributed to the hash of the job. Their digests are
+	// left out, so no digest of a Secret value ends up in the cluster.
+	inputsAnnKey = annKey + "/inputs"
+
+	// baseNameLabelKey is the label which holds the original name of the job
+	// on both the job and its pods, so all runs of a job can be selected.
+	baseNameLabelKey = annKey + "/base-name"
+)
+
+// stampProvenance records where the unique name of the job came from. The
+// annotations describe the original name and everything that went into the
+// hash, the base name label is set on the job and the pod labels of its kind.
+func stampProvenance(job *unstructured.Unstructured, kind WorkloadKind, original string, hash jobHash) error {
+	inputs := []string{}
+	for _, input := range hash.Inputs {
+		inputs = append(inputs, input.Key)
+	}
+
+	data, err := json.Marshal(inputs)
+	if err != nil {
+		return err
+	}
+
+	annotations := job.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[originalNameAnnKey] = original
+	annotations[hashAnnKey] = hash.Full
+	annotations[inputsAnnKey] = string(data)
+	job.SetAnnotations(annotations)
+
+	// label values have the same length limit as the job name
+	base := truncateName(original, maxNameLength)
+
+	labels := job.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[baseNameLabelKey] = base
+	job.SetLabels(labels)
+
+	for _, path := range kind.PodLabels {
+		segments, err := fieldPath(path)
+		if err != nil {
+			return err
+		}
+
+		if err := unstructured.SetNestedField(job.Object, base, append(segments, baseNameLabelKey)...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
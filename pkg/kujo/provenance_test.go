@@ -0,0 +1,78 @@
+package kujo
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestStampProvenance(t *testing.T) {
+	hash := jobHash{
+		Full:  "30b6ad9a70c1b8d13c4c55f0d7fe2bb44a2e55c57f2f8dd0ad02fabc9e37d23a",
+		Short: "k86kg7tt2c",
+		Inputs: []HashInput{
+			{Key: "Secret/default/mysecret/username", Digest: "8c6976e5"},
+			{Key: "ConfigMap/default/myconfig", Digest: "830efe40"},
+		},
+	}
+
+	tcs := map[string]struct {
+		name   string
+		inputs string
+		base   string
+	}{
+		"with a short name": {
+			name:   "migrate",
+			inputs: `["Secret/default/mysecret/username","ConfigMap/default/myconfig"]`,
+			base:   "migrate",
+		},
+		"with a long name": {
+			name:   strings.Repeat("a", 62) + "-bcdef",
+			inputs: `["Secret/default/mysecret/username","ConfigMap/default/myconfig"]`,
+			base:   strings.Repeat("a", 62),
+		},
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			job := unstructured.Unstructured{Object: map[string]interface{}{}}
+			job.SetName(tc.name + "-" + hash.Short)
+			job.SetAnnotations(map[string]string{"kujo.sphc.io": "true"})
+
+			if err := stampProvenance(&job, jobKind, tc.name, hash); err != nil {
+				t.Fatalf("Expected no error, got '%s'", err)
+			}
+
+			annotations := job.GetAnnotations()
+			if annotations["kujo.sphc.io"] != "true" {
+				t.Errorf("Expected existing annotations to be kept, got '%v'", annotations)
+			}
+
+			if annotations[originalNameAnnKey] != tc.name {
+				t.Errorf("Expected original name '%s', got '%s'", tc.name, annotations[originalNameAnnKey])
+			}
+
+			if annotations[hashAnnKey] != hash.Full {
+				t.Errorf("Expected hash '%s', got '%s'", hash.Full, annotations[hashAnnKey])
+			}
+
+			if annotations[inputsAnnKey] != tc.inputs {
+				t.Errorf("Expected inputs '%s', got '%s'", tc.inputs, annotations[inputsAnnKey])
+			}
+
+			if label := job.GetLabels()[baseNameLabelKey]; label != tc.base {
+				t.Errorf("Expected job label '%s', got '%s'", tc.base, label)
+			}
+
+			label, _, err := unstructured.NestedString(job.Object, "spec", "template", "metadata", "labels", baseNameLabelKey)
+			if err != nil {
+				t.Fatalf("Expected no error reading the pod label, got '%s'", err)
+			}
+
+			if label != tc.base {
+				t.Errorf("Expected pod label '%s', got '%s'", tc.base, label)
+			}
+		})
+	}
+}
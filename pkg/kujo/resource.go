@@ -4,8 +4,10 @@ import (
 	"io"
 	"log"
 	"strconv"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/yaml"
 )
 
@@ -16,7 +18,21 @@ const annKey = "kujo.sphc.io"
 // objects.
 // The objects are filtered based on their type. Only ConfigMap, Job and Secret
 // resources are returned.
+// List objects, like the ones returned by `kubectl get -o yaml`, are expanded
+// into their items. The items share their data with the List they were read
+// from, so changes to the items are reflected in the List.
 func ResourcesFromReader(rdr io.Reader) ([]unstructured.Unstructured, error) {
+	docs, err := documentsFromReader(rdr)
+	if err != nil {
+		return nil, err
+	}
+
+	return expandLists(docs)
+}
+
+// documentsFromReader parses the data of the reader into a slice of
+// unstructured objects, one for each document in the input.
+func documentsFromReader(rdr io.Reader) ([]unstructured.Unstructured, error) {
 	decoder := yaml.NewYAMLOrJSONDecoder(rdr, 1024)
 
 	var result []unstructured.Unstructured
@@ -35,32 +51,126 @@ func ResourcesFromReader(rdr io.Reader) ([]unstructured.Unstructured, error) {
 	return result, err
 }
 
-// validObjectKinds is a map of data which represents the items we're looking
-// for in a list of unstructured objects. It's mapped as Kind: []apiVersions.
+// expandLists replaces all List objects in the given slice with their items.
+// Nested Lists are expanded as well.
+func expandLists(uList []unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	var result []unstructured.Unstructured
+	for _, un := range uList {
+		if !isListResource(un) {
+			result = append(result, un)
+			continue
+		}
+
+		var items []unstructured.Unstructured
+		err := un.EachListItem(func(obj runtime.Object) error {
+			items = append(items, *obj.(*unstructured.Unstructured))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		expanded, err := expandLists(items)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, expanded...)
+	}
+
+	return result, nil
+}
+
+func isListResource(un unstructured.Unstructured) bool {
+	return strings.HasSuffix(un.GetKind(), "List") && un.IsList()
+}
+
+// validObjectKinds is a map of data which represents the configuration objects
+// we're looking for in a list of unstructured objects. It's mapped as Kind:
+// []apiVersions. The renamed kinds are described by their WorkloadKind.
 var validObjectKinds = map[string][]string{
-	"Job":       []string{"batch/v1"},
 	"Secret":    []string{"v1"},
 	"ConfigMap": []string{"v1"},
 }
 
+// isJobResource reports if the resource is a batch/v1 Job which opted in to
+// being renamed.
 func isJobResource(un unstructured.Unstructured) bool {
-	if un.GetKind() == "Job" {
-		unVersion := un.GetAPIVersion()
-		for _, version := range validObjectKinds["Job"] {
-			if version == unVersion {
-				ann := un.GetAnnotations()
-				if val, ok := ann[annKey]; ok {
-					pb, err := strconv.ParseBool(val)
-					if err != nil {
-						log.Println(err)
-						return false
-					}
-
-					return pb
-				}
-			}
+	return jobKind.matches(un) && isOptedIn(un)
+}
+
+// isOptedIn reports if the resource opted in to being renamed with the
+// `kujo.sphc.io` annotation.
+func isOptedIn(un unstructured.Unstructured) bool {
+	ann := un.GetAnnotations()
+	if val, ok := ann[annKey]; ok {
+		pb, err := strconv.ParseBool(val)
+		if err != nil {
+			log.Println(err)
+			return false
 		}
+
+		return pb
 	}
 
 	return false
 }
+
+// clusterScopedKinds are the kinds of the built-in resources which don't live
+// in a namespace.
+var clusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"CertificateSigningRequest":      true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"PodSecurityPolicy":              true,
+	"PriorityClass":                  true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+	"VolumeAttachment":               true,
+}
+
+// setNamespaces sets the resolved namespace on all namespaced objects which
+// don't have a namespace set yet.
+func setNamespaces(uList []unstructured.Unstructured, opts Options) {
+	for i, un := range uList {
+		if un.GetKind() == "" || clusterScopedKinds[un.GetKind()] || un.GetNamespace() != "" {
+			continue
+		}
+
+		uList[i].SetNamespace(opts.namespace(""))
+	}
+}
+
+// podSpecPaths are the paths of the pod specs within the built-in controllers
+// which run pods. The pod specs of workload kinds are declared by the kind.
+var podSpecPaths = map[string][][]string{
+	"PodTemplate":           {{"template", "spec"}},
+	"ReplicationController": {{"spec", "template", "spec"}},
+	"ReplicaSet":            {{"spec", "template", "spec"}},
+	"Deployment":            {{"spec", "template", "spec"}},
+	"StatefulSet":           {{"spec", "template", "spec"}},
+	"DaemonSet":             {{"spec", "template", "spec"}},
+	"CronJob":               {{"spec", "jobTemplate", "spec", "template", "spec"}},
+}
+
+// podSpecs returns the pod specs of the resource. The specs share their data
+// with the resource, so changes to them are reflected in the resource.
+func podSpecs(un unstructured.Unstructured, opts Options) []map[string]interface{} {
+	if kind, ok := workloadKind(un, opts); ok {
+		return kind.podSpecs(un.Object)
+	}
+
+	var specs []map[string]interface{}
+	for _, path := range podSpecPaths[un.GetKind()] {
+		if spec, ok := nestedMap(un.Object, path...); ok {
+			specs = append(specs, spec)
+		}
+	}
+
+	return specs
+}
@@ -18,6 +18,10 @@ func TestResourcesFromReader(t *testing.T) {
 			fixture:     "testdata/full-config.yaml",
 			resourceLen: 4,
 		},
+		"with a list of data in a List object": {
+			fixture:     "testdata/list-input.yaml",
+			resourceLen: 3,
+		},
 	}
 
 	for name, tc := range tcs {
@@ -0,0 +1,202 @@
+package kujo
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// suffixAnnKey is the annotation which opts a ConfigMap or Secret in or out of
+// being suffixed with its hash.
+const suffixAnnKey = annKey + "/suffix"
+
+// isSuffixedConfig reports if the ConfigMap or Secret is renamed to a name
+// with its hash. The annotation of the object takes precedence over the
+// global option.
+func isSuffixedConfig(un unstructured.Unstructured, opts Options) bool {
+	kind := un.GetKind()
+	if kind != "ConfigMap" && kind != "Secret" {
+		return false
+	}
+
+	valid := false
+	for _, version := range validObjectKinds[kind] {
+		if version == un.GetAPIVersion() {
+			valid = true
+		}
+	}
+
+	if !valid {
+		return false
+	}
+
+	if val, ok := un.GetAnnotations()[suffixAnnKey]; ok {
+		pb, err := strconv.ParseBool(val)
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+
+		return pb
+	}
+
+	return opts.SuffixConfig
+}
+
+// suffixConfig renames the ConfigMaps and Secrets which opted in to a name
+// suffixed with the hash of their content, and rewrites the references to
+// them in the pod specs of all resources in the list. The resources are
+// updated in place.
+func suffixConfig(resourceList []unstructured.Unstructured, opts Options) error {
+	var configs []int
+	for i, rs := range resourceList {
+		if isSuffixedConfig(rs, opts) {
+			configs = append(configs, i)
+		}
+	}
+
+	if len(configs) == 0 {
+		return nil
+	}
+
+	cm, err := hashedConfig(resourceList, opts)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]string{}
+	for _, i := range configs {
+		rs := resourceList[i]
+		key := configKey(rs.GetKind(), opts.namespace(rs.GetNamespace()), rs.GetName(), "")
+
+		hash, err := encodeHash(cm[key])
+		if err != nil {
+			return err
+		}
+
+		name := fmt.Sprintf("%s-%s", rs.GetName(), hash)
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("the name '%s' of %s '%s' is invalid: %s", name, rs.GetKind(), rs.GetName(), strings.Join(errs, ", "))
+		}
+
+		names[key] = name
+	}
+
+	for _, i := range configs {
+		rs := &resourceList[i]
+		rs.SetName(names[configKey(rs.GetKind(), opts.namespace(rs.GetNamespace()), rs.GetName(), "")])
+	}
+
+	for _, rs := range resourceList {
+		rename := configRenamer(opts.namespace(rs.GetNamespace()), names)
+		for _, pod := range podSpecs(rs, opts) {
+			renameConfigRefs(pod, rename)
+		}
+
+		if kind, ok := workloadKind(rs, opts); ok {
+			for _, container := range kind.containers(rs.Object) {
+				renameContainerConfigRefs(container, rename)
+			}
+		}
+	}
+
+	return nil
+}
+
+// volumeSecretRefs are the volume plugins which reference a Secret through
+// a LocalObjectReference, by the field which holds it.
+var volumeSecretRefs = map[string]string{
+	"cephfs":     "secretRef",
+	"cinder":     "secretRef",
+	"csi":        "nodePublishSecretRef",
+	"flexVolume": "secretRef",
+	"iscsi":      "secretRef",
+	"rbd":        "secretRef",
+	"scaleIO":    "secretRef",
+	"storageos":  "secretRef",
+}
+
+// configRenamer returns a function which rewrites the name of a ConfigMap or
+// Secret in the field of the object when it was renamed. The names are keyed
+// by the config key of the original object.
+func configRenamer(ns string, names map[string]string) func(obj map[string]interface{}, field, kind string) {
+	return func(obj map[string]interface{}, field, kind string) {
+		name, ok := obj[field].(string)
+		if !ok {
+			return
+		}
+
+		if renamed, ok := names[configKey(kind, ns, name, "")]; ok {
+			obj[field] = renamed
+		}
+	}
+}
+
+// renameConfigRefs rewrites the references to renamed ConfigMaps and Secrets
+// in the pod spec.
+func renameConfigRefs(pod map[string]interface{}, rename func(obj map[string]interface{}, field, kind string)) {
+	for _, field := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		for _, container := range mapsOf(pod[field]) {
+			renameContainerConfigRefs(container, rename)
+		}
+	}
+
+	for _, volume := range mapsOf(pod["volumes"]) {
+		if ref, ok := nestedMap(volume, "configMap"); ok {
+			rename(ref, "name", "ConfigMap")
+		}
+		if ref, ok := nestedMap(volume, "secret"); ok {
+			rename(ref, "secretName", "Secret")
+		}
+		if ref, ok := nestedMap(volume, "azureFile"); ok {
+			rename(ref, "secretName", "Secret")
+		}
+
+		if projected, ok := nestedMap(volume, "projected"); ok {
+			for _, source := range mapsOf(projected["sources"]) {
+				if ref, ok := nestedMap(source, "configMap"); ok {
+					rename(ref, "name", "ConfigMap")
+				}
+				if ref, ok := nestedMap(source, "secret"); ok {
+					rename(ref, "name", "Secret")
+				}
+			}
+		}
+
+		for plugin, field := range volumeSecretRefs {
+			if ref, ok := nestedMap(volume, plugin, field); ok {
+				rename(ref, "name", "Secret")
+			}
+		}
+	}
+
+	for _, secret := range mapsOf(pod["imagePullSecrets"]) {
+		rename(secret, "name", "Secret")
+	}
+}
+
+// renameContainerConfigRefs rewrites the references to renamed ConfigMaps and
+// Secrets in the environment of the container.
+func renameContainerConfigRefs(container map[string]interface{}, rename func(obj map[string]interface{}, field, kind string)) {
+	for _, env := range mapsOf(container["env"]) {
+		if ref, ok := nestedMap(env, "valueFrom", "configMapKeyRef"); ok {
+			rename(ref, "name", "ConfigMap")
+		}
+		if ref, ok := nestedMap(env, "valueFrom", "secretKeyRef"); ok {
+			rename(ref, "name", "Secret")
+		}
+	}
+
+	for _, envFrom := range mapsOf(container["envFrom"]) {
+		if ref, ok := nestedMap(envFrom, "configMapRef"); ok {
+			rename(ref, "name", "ConfigMap")
+		}
+		if ref, ok := nestedMap(envFrom, "secretRef"); ok {
+			rename(ref, "name", "Secret")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package kujo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSuffixConfig(t *testing.T) {
+	tcs := map[string]struct {
+		opts  Options
+		names map[string]string
+	}{
+		"with the annotation": {
+			names: map[string]string{
+				"settings":    "settings-22cbdd4cm8",
+				"credentials": "credentials",
+				"registry":    "registry",
+			},
+		},
+		"with the option": {
+			opts: Options{SuffixConfig: true},
+			names: map[string]string{
+				"settings":    "settings-22cbdd4cm8",
+				"credentials": "credentials-965m8gf6th",
+				"registry":    "registry",
+			},
+		},
+	}
+
+	input, err := ioutil.ReadFile("testdata/suffix-input.yaml")
+	if err != nil {
+		t.Fatalf("Expected no error reading the input file, got '%s'", err)
+	}
+
+	for name, tc := range tcs {
+		t.Run(name, func(t *testing.T) {
+			output, err := Convert(bytes.NewReader(input), tc.opts)
+			if err != nil {
+				t.Fatalf("Expected no error converting the input, got '%s'", err)
+			}
+
+			resources, err := ResourcesFromReader(bytes.NewReader(output))
+			if err != nil {
+				t.Fatalf("Expected no error reading the output, got '%s'", err)
+			}
+
+			names := []string{resources[0].GetName(), resources[1].GetName(), resources[2].GetName()}
+			expectedNames := []string{tc.names["settings"], tc.names["credentials"], tc.names["registry"]}
+			if !cmp.Equal(expectedNames, names) {
+				t.Errorf("Expected names to match, got diff %s", cmp.Diff(expectedNames, names))
+			}
+
+			refs := map[string][]string{
+				"env":       {"spec", "template", "spec", "containers", "0", "env", "0", "valueFrom", "secretKeyRef", "name"},
+				"envFrom":   {"spec", "template", "spec", "containers", "0", "envFrom", "0", "configMapRef", "name"},
+				"pull":      {"spec", "template", "spec", "imagePullSecrets", "0", "name"},
+				"configMap": {"spec", "template", "spec", "volumes", "0", "configMap", "name"},
+				"secret":    {"spec", "template", "spec", "volumes", "1", "secret", "secretName"},
+				"projected": {"spec", "template", "spec", "volumes", "2", "projected", "sources", "1", "secret", "name"},
+				"missing":   {"spec", "template", "spec", "volumes", "2", "projected", "sources", "2", "secret", "name"},
+			}
+
+			expected := map[string]string{
+				"env":       tc.names["credentials"],
+				"envFrom":   tc.names["settings"],
+				"pull":      tc.names["registry"],
+				"configMap": tc.names["settings"],
+				"secret":    tc.names["credentials"],
+				"projected": tc.names["credentials"],
+				"missing":   "other",
+			}
+
+			actual := map[string]string{}
+			for ref, path := range refs {
+				for _, rs := range resources {
+					if value, ok := nestedValue(rs, path); ok {
+						actual[ref] = value
+					}
+				}
+			}
+
+			if !cmp.Equal(expected, actual) {
+				t.Errorf("Expected references to match, got diff %s", cmp.Diff(expected, actual))
+			}
+		})
+	}
+}
+
+// nestedValue returns the string at the path in the resource, where numeric
+// fields index a list.
+func nestedValue(rs unstructured.Unstructured, path []string) (string, bool) {
+	var obj interface{} = rs.Object
+	for _, field := range path {
+		switch o := obj.(type) {
+		case map[string]interface{}:
+			obj = o[field]
+		case []interface{}:
+			i := int(field[0] - '0')
+			if i >= len(o) {
+				return "", false
+			}
+			obj = o[i]
+		default:
+			return "", false
+		}
+	}
+
+	value, ok := obj.(string)
+	return value, ok
+}
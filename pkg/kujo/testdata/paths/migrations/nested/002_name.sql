@@ -0,0 +1 @@
+ALTER TABLE users ADD name TEXT;